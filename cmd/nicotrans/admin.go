@@ -0,0 +1,720 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/hosts"
+	"github.com/hype5/nicotrans-go/pkg/metrics"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/onboard"
+	"github.com/hype5/nicotrans-go/pkg/translator"
+)
+
+// 관리 API 경로
+const adminAPIPath = "/admin/v1/"
+
+// 관리 API 토큰을 읽을 환경 변수
+const adminTokenEnv = "NICOTRANS_ADMIN_TOKEN"
+
+// 관리 API 로 캐시를 조회할 때 기본으로 반환할 개수
+const adminCacheLimit = 100
+
+// adminStatus 관리 API 가 반환하는 니코트랜스 상태
+type adminStatus struct {
+	StartedAt   time.Time        `json:"started_at"`
+	Uptime      float64          `json:"uptime_seconds"`
+	Mode        string           `json:"mode"`
+	Settings    adminSettings    `json:"settings"`
+	Requests    requestStats     `json:"requests"`
+	Translation translationStats `json:"translation"`
+	Cache       adminCacheStatus `json:"cache"`
+	Backends    []backendStatus  `json:"backends"`
+	Certificate adminCertificate `json:"certificate"`
+	Hosts       adminHostsStatus `json:"hosts"`
+	Errors      []errorRecord    `json:"errors"`
+}
+
+// adminSettings 실행 중에 바꿀 수 있는 번역 설정
+type adminSettings struct {
+	Platform  string   `json:"platform"`
+	Source    string   `json:"source"`
+	Target    string   `json:"target"`
+	Platforms []string `json:"platforms,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+// adminCacheStatus 번역 캐시 상태
+type adminCacheStatus struct {
+	Size    int     `json:"size"`
+	Entries int     `json:"entries"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	Evicted uint64  `json:"evicted"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// adminCertificate 루트 인증서 상태
+type adminCertificate struct {
+	Subject     string    `json:"subject"`
+	Fingerprint string    `json:"fingerprint"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	ExpiresIn   float64   `json:"expires_in_days"`
+}
+
+// adminCacheEntries 캐시에 기억한 번역 결과 목록
+type adminCacheEntries struct {
+	adminCacheStatus
+	Items []adminCacheEntry `json:"items"`
+}
+
+type adminCacheEntry struct {
+	Platform   string `json:"platform"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Text       string `json:"text"`
+	Translated string `json:"translated"`
+}
+
+// adminHostsStatus 호스트 파일 상태
+type adminHostsStatus struct {
+	Edit    bool              `json:"edit"`
+	Enabled bool              `json:"enabled"`
+	Path    string            `json:"path"`
+	Entries []adminHostsEntry `json:"entries"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type adminHostsEntry struct {
+	IP      string `json:"ip"`
+	Host    string `json:"host"`
+	Present bool   `json:"present"`
+}
+
+// initAdminToken 관리 API 토큰을 파일에서 불러오거나 새로 만들어 환경 변수에 저장합니다
+// 권한을 내린 자식 프로세스는 토큰 파일을 읽을 수 없어도 환경 변수로 토큰을 물려받습니다
+func initAdminToken() error {
+	if os.Getenv(adminTokenEnv) != "" {
+		return nil
+	}
+
+	data, e := ioutil.ReadFile(*adminTokenPath)
+	if e != nil && !os.IsNotExist(e) {
+		return fmt.Errorf("관리 API 토큰을 불러올 수 없습니다: %s", e)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		b := make([]byte, 32)
+		if _, e := rand.Read(b); e != nil {
+			return e
+		}
+
+		token = hex.EncodeToString(b)
+
+		if e := ioutil.WriteFile(*adminTokenPath, []byte(token+"\n"), 0600); e != nil {
+			return fmt.Errorf("관리 API 토큰을 저장할 수 없습니다: %s", e)
+		}
+
+		log.Infof("관리 API 토큰을 만들었습니다: %s", *adminTokenPath)
+	}
+
+	return os.Setenv(adminTokenEnv, token)
+}
+
+// initAdmin 대시보드와 관리 API 를 실행합니다
+func initAdmin(issuer *certificate.Issuer, l net.Listener) {
+	if l == nil {
+		return
+	}
+
+	token := os.Getenv(adminTokenEnv)
+	if token == "" {
+		log.Errorf("관리 API 토큰이 없어 관리 API 를 사용할 수 없습니다")
+	}
+
+	if host, _, e := net.SplitHostPort(*adminListen); e == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			log.Warningf("대시보드를 다른 기기에서도 접속할 수 있습니다: %s", *adminListen)
+		}
+	}
+
+	server := &http.Server{Handler: adminHandler(issuer, token)}
+	onShutdownServer("대시보드 종료", server)
+
+	go func() {
+		log.Infof("대시보드를 실행합니다: http://%s/", *adminListen)
+
+		if e := server.Serve(l); e != http.ErrServerClosed {
+			log.Panic("대시보드를 여는 중 오류가 발생했습니다\n", e)
+		}
+	}()
+}
+
+// adminHandler 대시보드 페이지와 관리 API 를 처리하는 핸들러를 만듭니다
+// 대시보드 페이지는 데이터가 없는 정적 페이지라서 토큰 없이 열 수 있고 관리 API 는 토큰이 필요합니다
+func adminHandler(issuer *certificate.Issuer, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if e := dashboardTemplate.Execute(w, map[string]string{"API": adminAPIPath}); e != nil {
+			http.Error(w, e.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	api := http.NewServeMux()
+
+	api.HandleFunc(adminAPIPath+"status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newAdminStatus(issuer))
+	})
+
+	api.HandleFunc(adminAPIPath+"settings", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodPost) {
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			var request adminSettings
+			if !readJSON(w, r, &request) {
+				return
+			}
+
+			if e := updateSettings(request); e != nil {
+				writeError(w, http.StatusBadRequest, e)
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, newAdminSettings())
+	})
+
+	api.HandleFunc(adminAPIPath+"cache", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet, http.MethodDelete) {
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			n := cache.Flush()
+			log.Infof("관리 API 로 번역 캐시 %d개를 지웠습니다", n)
+			writeJSON(w, http.StatusOK, map[string]int{"flushed": n})
+			return
+		}
+
+		limit := adminCacheLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var e error
+			if limit, e = strconv.Atoi(value); e != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("limit 값이 숫자가 아닙니다: %s", value))
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, newAdminCacheEntries(limit))
+	})
+
+	api.HandleFunc(adminAPIPath+"hosts", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var request struct {
+				Enabled *bool `json:"enabled"`
+			}
+
+			if !readJSON(w, r, &request) {
+				return
+			}
+
+			if request.Enabled == nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("enabled 값이 필요합니다"))
+				return
+			}
+
+			if e := setHostsRedirect(*request.Enabled); e != nil {
+				writeError(w, http.StatusConflict, fmt.Errorf("호스트 파일을 수정할 수 없습니다: %s", e))
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, newAdminHostsStatus())
+	})
+
+	api.HandleFunc(adminAPIPath+"certificate", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			if e := canRotateCertificate(); e != nil {
+				writeError(w, http.StatusConflict, fmt.Errorf("새 인증서를 만들 수 없습니다: %s", e))
+				return
+			}
+
+			log.Info("관리 API 로 인증서를 교체합니다")
+
+			if e := rotateCertificate(issuer); e != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("인증서를 교체할 수 없습니다: %s", e))
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, newAdminCertificate(issuer))
+	})
+
+	mux.Handle(adminAPIPath, requireToken(token, api))
+
+	// 프로메테우스와 서비스 관리자가 읽을 수 있도록 지표와 상태 확인은 토큰 없이 응답하기
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.HandleFunc("/healthz", handleHealth)
+	mux.HandleFunc("/readyz", readinessHandler(issuer))
+
+	return mux
+}
+
+// requireToken Authorization 헤더의 Bearer 토큰이 맞을 때만 요청을 처리합니다
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.Header.Get("Authorization")
+		if !strings.HasPrefix(given, "Bearer ") || token == "" ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(given, "Bearer ")), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nicotrans"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("관리 API 토큰이 필요합니다"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// updateSettings 관리 API 로 받은 번역 설정을 적용합니다, 비어있는 값은 바꾸지 않습니다
+// 바꾼 값은 파일에 저장하지 않지만 다시 실행할 때까지 명령줄과 설정 파일보다 우선합니다
+func updateSettings(request adminSettings) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	current := currentSettings()
+	s := *current
+
+	if request.Platform != "" {
+		s.Platform = request.Platform
+	}
+
+	if request.Source != "" {
+		s.Source = request.Source
+	}
+
+	if request.Target != "" {
+		s.Target = request.Target
+	}
+
+	if !translator.IsPlatform(s.Platform) {
+		return fmt.Errorf("%s 값은 사용할 수 있는 번역 플랫폼이 아닙니다", s.Platform)
+	}
+
+	for _, language := range []string{s.Source, s.Target} {
+		if !translator.IsLanguage(s.Platform, language) {
+			return fmt.Errorf("%s 은 %s 에서 사용할 수 없는 언어입니다", language, s.Platform)
+		}
+	}
+
+	for name, value := range map[string]string{"lang-platform": s.Platform, "lang-source": s.Source, "lang-target": s.Target} {
+		if e := flag.Set(name, value); e != nil {
+			return e
+		}
+
+		runtimeOverrides[name] = value
+	}
+
+	setSettings(&s)
+
+	log.Infof("관리 API 로 번역 설정을 바꿨습니다: %s, %s → %s", s.Platform, s.Source, s.Target)
+
+	return nil
+}
+
+func newAdminSettings() adminSettings {
+	s := currentSettings()
+
+	return adminSettings{
+		Platform:  s.Platform,
+		Source:    s.Source,
+		Target:    s.Target,
+		Platforms: translator.Platforms(),
+		Languages: translator.Languages(s.Platform),
+	}
+}
+
+// newAdminStatus 현재 상태를 모읍니다
+func newAdminStatus(issuer *certificate.Issuer) adminStatus {
+	requests, translations, backends, errors := snapshotStats()
+
+	status := adminStatus{
+		StartedAt:   startedAt,
+		Uptime:      time.Since(startedAt).Seconds(),
+		Mode:        "hosts",
+		Settings:    newAdminSettings(),
+		Requests:    requests,
+		Translation: translations,
+		Cache:       newAdminCacheStatus(),
+		Backends:    backends,
+		Certificate: newAdminCertificate(issuer),
+		Hosts:       newAdminHostsStatus(),
+		Errors:      errors,
+	}
+
+	if *proxyPort > 0 {
+		status.Mode = "proxy"
+	}
+
+	return status
+}
+
+func newAdminCacheStatus() adminCacheStatus {
+	stats := cache.Stats()
+
+	status := adminCacheStatus{
+		Size:    stats.Size,
+		Entries: stats.Len,
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		Evicted: stats.Evicted,
+	}
+
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		status.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	return status
+}
+
+func newAdminCacheEntries(limit int) adminCacheEntries {
+	entries := adminCacheEntries{
+		adminCacheStatus: newAdminCacheStatus(),
+		Items:            []adminCacheEntry{},
+	}
+
+	for _, entry := range cache.Entries(limit) {
+		entries.Items = append(entries.Items, adminCacheEntry{
+			Platform:   entry.Key.Platform,
+			Source:     entry.Key.Source,
+			Target:     entry.Key.Target,
+			Text:       entry.Key.Text,
+			Translated: entry.Translated,
+		})
+	}
+
+	return entries
+}
+
+func newAdminCertificate(issuer *certificate.Issuer) adminCertificate {
+	ca := issuer.CA()
+
+	return adminCertificate{
+		Subject:     ca.Subject.CommonName,
+		Fingerprint: onboard.Fingerprint(ca),
+		NotBefore:   ca.NotBefore,
+		NotAfter:    ca.NotAfter,
+		ExpiresIn:   time.Until(ca.NotAfter).Hours() / 24,
+	}
+}
+
+// newAdminHostsStatus 가로채는 호스트가 호스트 파일에 있는지 확인합니다
+func newAdminHostsStatus() adminHostsStatus {
+	status := adminHostsStatus{
+		Edit: *hostsEdit && *proxyPort == 0,
+		Path: *hostsPath,
+	}
+
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		status.Error = e.Error()
+	} else {
+		status.Enabled = len(file.Block()) > 0
+	}
+
+	for _, host := range nico.Hosts {
+		status.Entries = append(status.Entries, adminHostsEntry{
+			IP:      *serverIP,
+			Host:    host,
+			Present: file != nil && file.Has(*serverIP, host),
+		})
+	}
+
+	return status
+}
+
+// allowMethods 허용하지 않은 메소드라면 405 를 응답합니다
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s 메소드는 사용할 수 없습니다", r.Method))
+
+	return false
+}
+
+// readJSON 요청 본문을 JSON 으로 읽습니다, 잘못됐다면 400 을 응답합니다
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if e := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); e != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("요청 형식이 잘못됐습니다: %s", e))
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, e error) {
+	writeJSON(w, status, map[string]string{"error": e.Error()})
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="ko">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>니코트랜스 대시보드</title>
+<style>
+body { font-family: sans-serif; max-width: 64em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+section { border: 1px solid #ccc; border-radius: 4px; padding: 0.5em 1em; margin-bottom: 1em; }
+h2 { font-size: 1.1em; margin: 0.3em 0; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { text-align: left; padding: 0.2em 0.5em; border-bottom: 1px solid #eee; }
+.ok { color: #080; }
+.bad { color: #c00; }
+#message { min-height: 1.5em; }
+</style>
+</head>
+<body>
+<h1>니코트랜스 대시보드</h1>
+
+<section>
+<h2>번역 설정</h2>
+<form id="settings">
+<label>번역기 <select name="platform" id="platform"></select></label>
+<label>원문 <select name="source" id="source"></select></label>
+<label>번역 <select name="target" id="target"></select></label>
+<button type="submit">적용</button>
+</form>
+<div id="message"></div>
+</section>
+
+<section>
+<h2>관리</h2>
+<button id="hosts">호스트 파일 리디렉션</button>
+<button id="flush">번역 캐시 비우기</button>
+<button id="reissue">인증서 다시 만들기</button>
+</section>
+
+<section>
+<h2>상태</h2>
+<table id="summary"></table>
+</section>
+
+<section>
+<h2>번역기</h2>
+<table id="backends"></table>
+</section>
+
+<section>
+<h2>최근 요청</h2>
+<table id="requests"></table>
+</section>
+
+<section>
+<h2>최근 오류</h2>
+<table id="errors"></table>
+</section>
+
+<script>
+var api = "{{.API}}";
+var hostsEnabled = false;
+
+// 관리 API 토큰은 주소의 #token= 이나 입력받은 값을 브라우저에 저장해서 사용
+var match = location.hash.match(/token=([^&]+)/);
+if (match) {
+	localStorage.setItem("nicotrans-token", decodeURIComponent(match[1]));
+	history.replaceState(null, "", location.pathname);
+}
+
+function token() {
+	var t = localStorage.getItem("nicotrans-token");
+	if (!t) {
+		t = prompt("관리 API 토큰을 입력하세요 (nicotrans.admin.token 파일)");
+		if (t) localStorage.setItem("nicotrans-token", t.trim());
+	}
+	return t ? t.trim() : "";
+}
+
+function request(method, path, body) {
+	var options = { method: method, headers: { "Authorization": "Bearer " + token() } };
+	if (body !== undefined) {
+		options.headers["Content-Type"] = "application/json";
+		options.body = JSON.stringify(body);
+	}
+	return fetch(api + path, options).then(function (r) {
+		if (r.status === 401) localStorage.removeItem("nicotrans-token");
+		return r.json().then(function (data) {
+			if (!r.ok) throw new Error(data.error || r.statusText);
+			return data;
+		});
+	});
+}
+
+function message(text) {
+	document.getElementById("message").textContent = text;
+}
+
+function cell(row, text, cls) {
+	var td = document.createElement("td");
+	td.textContent = text;
+	if (cls) td.className = cls;
+	row.appendChild(td);
+}
+
+function fill(table, header, rows) {
+	table.innerHTML = "";
+	if (header) {
+		var tr = table.insertRow();
+		header.forEach(function (h) { var th = document.createElement("th"); th.textContent = h; tr.appendChild(th); });
+	}
+	rows.forEach(function (r) {
+		var tr = table.insertRow();
+		r.forEach(function (c) { Array.isArray(c) ? cell(tr, c[0], c[1]) : cell(tr, c); });
+	});
+}
+
+function time(t) {
+	return t && t.indexOf("0001-") !== 0 ? new Date(t).toLocaleString() : "-";
+}
+
+function options(select, values, selected) {
+	if (document.activeElement === select) return;
+	select.innerHTML = "";
+	values.forEach(function (v) {
+		var o = document.createElement("option");
+		o.value = o.textContent = v;
+		o.selected = v === selected;
+		select.appendChild(o);
+	});
+}
+
+function render(s) {
+	options(document.getElementById("platform"), s.settings.platforms, s.settings.platform);
+	options(document.getElementById("source"), s.settings.languages, s.settings.source);
+	options(document.getElementById("target"), s.settings.languages, s.settings.target);
+
+	hostsEnabled = s.hosts.enabled;
+	var button = document.getElementById("hosts");
+	button.textContent = hostsEnabled ? "호스트 파일 리디렉션 끄기" : "호스트 파일 리디렉션 켜기";
+	button.disabled = s.mode === "proxy";
+
+	var hosts = s.hosts.entries.map(function (e) { return e.ip + " " + e.host + (e.present ? " ✔" : " ✘"); }).join(", ");
+	fill(document.getElementById("summary"), null, [
+		["실행 시각", time(s.started_at)],
+		["모드", s.mode === "proxy" ? "HTTP 프록시" : "호스트 파일"],
+		["처리 중인 요청", s.requests.active],
+		["처리한 요청", s.requests.total],
+		["평균 번역 시간", s.translation.average_ms.toFixed(0) + " ms (마지막 " + s.translation.last_ms.toFixed(0) + " ms)"],
+		["캐시 적중률", (s.cache.hit_rate * 100).toFixed(1) + "% (" + s.cache.entries + " / " + s.cache.size + ")"],
+		["인증서", s.certificate.subject + ", " + time(s.certificate.not_after) + " 만료 (" + s.certificate.expires_in_days.toFixed(0) + "일 남음)"],
+		["인증서 지문", s.certificate.fingerprint],
+		["호스트 파일", [s.hosts.path + ": " + (s.hosts.error || hosts), s.hosts.error ? "bad" : ""]]
+	]);
+
+	fill(document.getElementById("backends"), ["번역기", "상태", "마지막 성공", "마지막 오류", "오류"],
+		s.backends.map(function (b) {
+			return [b.platform, [b.healthy ? "정상" : "오류", b.healthy ? "ok" : "bad"], time(b.last_success), time(b.last_error), b.error || ""];
+		}));
+
+	fill(document.getElementById("requests"), ["시각", "클라이언트", "상태", "코멘트", "번역", "캐시", "언어", "시간"],
+		s.requests.recent.map(function (r) {
+			return [time(r.time), r.client, [r.status, r.status >= 400 ? "bad" : ""], r.comments, r.translated, r.cached, r.language || "", r.duration_ms.toFixed(0) + " ms"];
+		}));
+
+	fill(document.getElementById("errors"), ["시각", "오류"],
+		s.errors.map(function (e) { return [time(e.time), [e.message, "bad"]]; }));
+}
+
+function refresh() {
+	request("GET", "status").then(render).catch(function (e) {
+		message("상태를 불러올 수 없습니다: " + e.message);
+	});
+}
+
+function action(promise, done) {
+	promise.then(function (data) {
+		message(done(data));
+		refresh();
+	}).catch(function (e) {
+		message(e.message);
+	});
+}
+
+document.getElementById("settings").addEventListener("submit", function (event) {
+	event.preventDefault();
+	var form = event.target;
+	action(request("PUT", "settings", { platform: form.platform.value, source: form.source.value, target: form.target.value }),
+		function () { return "적용했습니다"; });
+});
+
+document.getElementById("hosts").addEventListener("click", function () {
+	action(request("PUT", "hosts", { enabled: !hostsEnabled }),
+		function (h) { return h.enabled ? "호스트 파일 리디렉션을 켰습니다" : "호스트 파일 리디렉션을 껐습니다"; });
+});
+
+document.getElementById("flush").addEventListener("click", function () {
+	action(request("DELETE", "cache"), function (r) { return "번역 캐시 " + r.flushed + "개를 지웠습니다"; });
+});
+
+document.getElementById("reissue").addEventListener("click", function () {
+	if (!confirm("새 루트 인증서를 만들면 다른 기기에도 다시 설치해야 합니다. 계속할까요?")) return;
+	action(request("POST", "certificate"), function (c) { return "새 인증서를 만들었습니다: " + c.fingerprint; });
+});
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`))
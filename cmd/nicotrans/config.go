@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/translator"
+	"gopkg.in/yaml.v2"
+)
+
+// 실행 중에 다시 불러올 수 있는 설정, 나머지는 다시 실행해야 적용됩니다
+var reloadable = map[string]bool{
+	"lang-platform": true,
+	"lang-source":   true,
+	"lang-target":   true,
+	"glossary":      true,
+	"filters":       true,
+	"clients":       true,
+	"log-level":     true,
+}
+
+// configInterval 설정 파일이 바뀌었는지 확인할 주기
+var configInterval = 2 * time.Second
+
+// 설정을 다시 불러오라는 요청 (SIGHUP)
+var reloadRequests = make(chan struct{}, 1)
+
+// 설정 파일과 관리 API 가 동시에 설정을 바꾸지 않도록 잠그기
+var reloadMu sync.Mutex
+
+// 명령줄에서 직접 지정한 플래그와 값, 설정 파일보다 우선합니다
+var commandLineFlags = map[string]string{}
+
+// 관리 API 로 바꾼 플래그와 값, 다시 실행할 때까지 명령줄과 설정 파일보다 우선합니다
+// reloadMu 를 잠근 상태에서만 사용합니다
+var runtimeOverrides = map[string]string{}
+
+// translateSettings 번역할 때 사용하는 설정
+// 설정을 다시 불러오면 요청 중간에 값이 섞이지 않도록 통째로 교체합니다
+type translateSettings struct {
+	Platform string
+	Source   string
+	Target   string
+	Glossary []glossaryEntry
+	Filters  []*regexp.Regexp
+	Clients  []clientLanguage
+}
+
+// glossaryEntry 번역하기 전에 바꿀 단어
+type glossaryEntry struct {
+	From string
+	To   string
+}
+
+var settings struct {
+	mu      sync.RWMutex
+	current *translateSettings
+}
+
+// currentSettings 현재 번역 설정을 반환합니다
+func currentSettings() *translateSettings {
+	settings.mu.RLock()
+	defer settings.mu.RUnlock()
+
+	return settings.current
+}
+
+func setSettings(s *translateSettings) {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	settings.current = s
+}
+
+// filtered 번역하지 않을 코멘트인지?
+func (s *translateSettings) filtered(content string) bool {
+	for _, filter := range s.Filters {
+		if filter.MatchString(content) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceGlossary 코멘트의 단어를 단어 목록대로 바꿉니다
+func (s *translateSettings) replaceGlossary(content string) string {
+	for _, entry := range s.Glossary {
+		content = strings.ReplaceAll(content, entry.From, entry.To)
+	}
+
+	return content
+}
+
+// config 설정 파일 내용
+type config struct {
+	// 플래그 이름과 값
+	values  map[string]string
+	hosts   []string
+	filters []string
+	// 클라이언트 아이피별 번역될 언어
+	clients map[string]string
+}
+
+// readConfig YAML 설정 파일을 읽습니다, 항목 이름은 플래그 이름과 같습니다
+func readConfig(path string) (*config, error) {
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+
+	var raw map[string]interface{}
+	if e := yaml.Unmarshal(data, &raw); e != nil {
+		return nil, fmt.Errorf("%s 파일의 형식이 잘못됐습니다: %s", path, e)
+	}
+
+	c := &config{values: map[string]string{}}
+
+	for key, value := range raw {
+		var e error
+
+		switch key {
+		case "hosts":
+			c.hosts, e = configList(value)
+		case "filters":
+			c.filters, e = configList(value)
+		case "clients":
+			c.clients, e = configMap(value)
+		case "config":
+			e = fmt.Errorf("설정 파일에서 다른 설정 파일을 지정할 수 없습니다")
+		default:
+			if flag.Lookup(key) == nil {
+				e = fmt.Errorf("알 수 없는 설정입니다")
+			} else {
+				c.values[key], e = configValue(value)
+			}
+		}
+
+		if e != nil {
+			return nil, fmt.Errorf("%s 파일의 %s 항목: %s", path, key, e)
+		}
+	}
+
+	return c, nil
+}
+
+// configValue 설정 값을 플래그 값으로 바꿉니다, 목록은 쉼표로 이어붙입니다
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		list, e := configList(v)
+		if e != nil {
+			return "", e
+		}
+
+		return strings.Join(list, ","), nil
+	case map[interface{}]interface{}:
+		return "", fmt.Errorf("값이 하나이거나 목록이어야 합니다")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func configList(value interface{}) ([]string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("목록이어야 합니다")
+	}
+
+	list := make([]string, len(items))
+	for i, item := range items {
+		switch item.(type) {
+		case []interface{}, map[interface{}]interface{}, nil:
+			return nil, fmt.Errorf("%d번째 값이 잘못됐습니다", i+1)
+		}
+
+		list[i] = fmt.Sprint(item)
+	}
+
+	return list, nil
+}
+
+func configMap(value interface{}) (map[string]string, error) {
+	items, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("키와 값의 목록이어야 합니다")
+	}
+
+	m := make(map[string]string, len(items))
+	for key, item := range items {
+		switch item.(type) {
+		case []interface{}, map[interface{}]interface{}, nil:
+			return nil, fmt.Errorf("%v 의 값이 잘못됐습니다", key)
+		}
+
+		m[fmt.Sprint(key)] = fmt.Sprint(item)
+	}
+
+	return m, nil
+}
+
+// initConfig 설정 파일을 불러와 명령줄에서 지정하지 않은 플래그에 적용합니다
+func initConfig() error {
+	flag.Visit(func(f *flag.Flag) {
+		commandLineFlags[f.Name] = f.Value.String()
+	})
+
+	var filters []string
+	var clients map[string]string
+
+	if *configPath != "" {
+		c, e := readConfig(*configPath)
+		if e != nil {
+			return e
+		}
+
+		for _, key := range c.keys() {
+			if _, ok := commandLineFlags[key]; ok {
+				continue
+			}
+
+			if e := flag.Set(key, c.values[key]); e != nil {
+				return fmt.Errorf("%s 파일의 %s 항목 값이 잘못됐습니다: %s", *configPath, key, e)
+			}
+		}
+
+		if len(c.hosts) > 0 {
+			nico.Hosts = c.hosts
+		}
+
+		filters = c.filters
+		clients = c.clients
+	}
+
+	if e := validateFlags(); e != nil {
+		return e
+	}
+
+	s, e := newTranslateSettings(*langPlatform, *langSource, *langTarget, *glossaryPath, filters, clients)
+	if e != nil {
+		return e
+	}
+
+	setSettings(s)
+
+	return nil
+}
+
+// validateFlags 다시 실행해야 적용되는 설정을 검사합니다
+func validateFlags() error {
+	for name, port := range map[string]int{"port": *serverPort, "proxy-port": *proxyPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("%s 값 %d 은 포트 번호가 아닙니다", name, port)
+		}
+	}
+
+	// 대부분의 브라우저가 Ed25519 인증서를 신뢰하지 않음
+	switch *certKeyAlgorithm {
+	case certificate.RSA, certificate.ECDSA:
+	default:
+		return fmt.Errorf("cert-key-algorithm 값은 rsa, ecdsa 중 하나여야 합니다: %s", *certKeyAlgorithm)
+	}
+
+	if *certCheckInterval <= 0 {
+		return fmt.Errorf("cert-check-interval 값은 0 보다 커야 합니다: %s", *certCheckInterval)
+	}
+
+	switch *dnsNetwork {
+	case "ip", "ip4", "ip6":
+	default:
+		return fmt.Errorf("dns-network 값은 ip, ip4, ip6 중 하나여야 합니다: %s", *dnsNetwork)
+	}
+
+	if len(nico.Hosts) == 0 {
+		return fmt.Errorf("가로챌 호스트가 하나 이상 있어야 합니다")
+	}
+
+	// 루트 인증서는 니코니코 도메인만 허용하므로 다른 도메인의 호스트는 가로챌 수 없음
+	for _, host := range nico.Hosts {
+		if !nico.InDomains(host) {
+			return fmt.Errorf("%s 호스트는 %s 도메인 아래에 있어야 합니다", host, strings.Join(nico.Domains, ", "))
+		}
+	}
+
+	if _, e := proxyClients(); e != nil {
+		return e
+	}
+
+	return nil
+}
+
+// proxyClients HTTP 프록시를 사용할 수 있는 클라이언트 대역을 반환합니다
+func proxyClients() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, addr := range strings.Split(*proxyAllow, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+
+		network, e := parseNetwork(addr)
+		if e != nil {
+			return nil, fmt.Errorf("proxy-allow 의 %s 은 아이피나 CIDR 대역이 아닙니다", addr)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// newTranslateSettings 번역 설정을 검사하고 단어 목록과 패턴을 불러옵니다
+func newTranslateSettings(platform, source, target, glossary string, filters []string, clients map[string]string) (*translateSettings, error) {
+	if !translator.IsPlatform(platform) {
+		return nil, fmt.Errorf("%s 값은 사용할 수 있는 번역 플랫폼이 아닙니다", platform)
+	}
+
+	if source == "" || target == "" {
+		return nil, fmt.Errorf("번역할 언어와 번역될 언어를 지정해야 합니다")
+	}
+
+	for _, language := range []string{source, target} {
+		if !translator.IsLanguage(platform, language) {
+			return nil, fmt.Errorf("%s 은 %s 에서 사용할 수 없는 언어입니다", language, platform)
+		}
+	}
+
+	s := &translateSettings{
+		Platform: platform,
+		Source:   source,
+		Target:   target,
+	}
+
+	if glossary != "" {
+		entries, e := readGlossary(glossary)
+		if e != nil {
+			return nil, e
+		}
+
+		s.Glossary = entries
+	}
+
+	for _, pattern := range filters {
+		filter, e := regexp.Compile(pattern)
+		if e != nil {
+			return nil, fmt.Errorf("%s 패턴이 잘못됐습니다: %s", pattern, e)
+		}
+
+		s.Filters = append(s.Filters, filter)
+	}
+
+	var e error
+	if s.Clients, e = parseClients(platform, clients); e != nil {
+		return nil, e
+	}
+
+	return s, nil
+}
+
+// readGlossary 한 줄에 하나씩 "원문=바꿀 단어" 형식으로 적은 단어 목록을 불러옵니다
+// 짧은 단어가 긴 단어의 일부를 먼저 바꾸지 않도록 긴 단어부터 바꿉니다
+func readGlossary(path string) ([]glossaryEntry, error) {
+	file, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+
+	defer file.Close()
+
+	var entries []glossaryEntry
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%s 파일 %d번째 줄이 잘못됐습니다: %s", path, line, text)
+		}
+
+		entries = append(entries, glossaryEntry{
+			From: strings.TrimSpace(parts[0]),
+			To:   strings.TrimSpace(parts[1]),
+		})
+	}
+
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].From) > len(entries[j].From)
+	})
+
+	return entries, nil
+}
+
+// watchConfig 설정 파일이 바뀌거나 SIGHUP 을 받으면 설정을 다시 불러옵니다
+func watchConfig() {
+	if *configPath == "" {
+		return
+	}
+
+	modTime := configModTime()
+
+	ticker := time.NewTicker(configInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t := configModTime()
+			if t.Equal(modTime) {
+				continue
+			}
+
+			modTime = t
+			log.Info("설정 파일이 바뀌었습니다")
+		case <-reloadRequests:
+			modTime = configModTime()
+			log.Info("설정 파일을 다시 불러옵니다")
+		}
+
+		if e := reloadConfig(); e != nil {
+			log.Errorf("설정 파일을 다시 불러올 수 없어 이전 설정을 계속 사용합니다: %s", e)
+		}
+	}
+}
+
+func configModTime() time.Time {
+	info, e := os.Stat(*configPath)
+	if e != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// reloadConfig 설정 파일을 다시 읽어 실행 중에 바꿀 수 있는 설정만 적용합니다
+// 설정이 잘못됐다면 아무것도 바꾸지 않습니다
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	c, e := readConfig(*configPath)
+	if e != nil {
+		return e
+	}
+
+	value := c.value
+
+	// 설정 파일에서 지운 항목도 기본값으로 돌아가므로 모든 플래그 비교하기
+	flag.VisitAll(func(f *flag.Flag) {
+		if !reloadable[f.Name] && !flagValueEqual(f, value(f.Name)) {
+			log.Warningf("%s 설정은 다시 실행해야 적용됩니다", f.Name)
+		}
+	})
+
+	if len(c.hosts) > 0 && strings.Join(c.hosts, ",") != strings.Join(nico.Hosts, ",") {
+		log.Warningf("hosts 설정은 다시 실행해야 적용됩니다")
+	}
+
+	s, e := newTranslateSettings(value("lang-platform"), value("lang-source"), value("lang-target"), value("glossary"), c.filters, c.clients)
+	if e != nil {
+		return e
+	}
+
+	level, e := parseLogLevel(value("log-level"))
+	if e != nil {
+		return e
+	}
+
+	for name := range reloadable {
+		if f := flag.Lookup(name); f != nil {
+			f.Value.Set(value(name))
+		}
+	}
+
+	setSettings(s)
+	setLogLevel(level)
+
+	log.Infof("번역 설정을 적용했습니다: %s, %s → %s", s.Platform, s.Source, s.Target)
+
+	return nil
+}
+
+// value 관리 API 로 바꾼 값, 명령줄 값, 설정 파일 값, 기본값 순서로 먼저 찾은 플래그 값을 반환합니다
+// 이전에 적용한 값을 쓰지 않으므로 설정 파일에서 항목을 지우면 기본값으로 돌아갑니다
+func (c *config) value(name string) string {
+	if v, ok := runtimeOverrides[name]; ok {
+		return v
+	}
+
+	if v, ok := commandLineFlags[name]; ok {
+		return v
+	}
+
+	if v, ok := c.values[name]; ok {
+		return v
+	}
+
+	return flag.Lookup(name).DefValue
+}
+
+// flagValueEqual 플래그의 현재 값이 주어진 값과 같은지?
+// 24h 와 24h0m0s 처럼 표기만 다른 값은 같은 값으로 봅니다
+func flagValueEqual(f *flag.Flag, value string) bool {
+	if f.Value.String() == value {
+		return true
+	}
+
+	parsed, ok := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+	if !ok || parsed.Set(value) != nil {
+		return false
+	}
+
+	return parsed.String() == f.Value.String()
+}
+
+// keys 플래그 이름을 정렬해서 반환합니다
+func (c *config) keys() []string {
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/nico"
+)
+
+// withConfig 설정 파일 경로와 명령줄 플래그를 바꾸고 테스트가 끝나면 되돌립니다
+func withConfig(t *testing.T, commandLine map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	savedPath, savedFlags, savedOverrides, savedSettings := *configPath, commandLineFlags, runtimeOverrides, currentSettings()
+
+	saved := map[string]string{}
+	for name := range reloadable {
+		if f := flag.Lookup(name); f != nil {
+			saved[name] = f.Value.String()
+		}
+	}
+
+	t.Cleanup(func() {
+		*configPath, commandLineFlags, runtimeOverrides = savedPath, savedFlags, savedOverrides
+
+		for name, value := range saved {
+			flag.Set(name, value)
+		}
+
+		setSettings(savedSettings)
+	})
+
+	*configPath, commandLineFlags, runtimeOverrides = path, commandLine, map[string]string{}
+
+	return path
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if e := ioutil.WriteFile(path, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := withConfig(t, map[string]string{"lang-source": "ja"})
+
+	tests := []struct {
+		name    string
+		content string
+		source  string
+		target  string
+		fail    bool
+	}{
+		{"설정 파일 값 적용", "lang-target: en\n", "ja", "en", false},
+		{"명령줄 값이 우선", "lang-source: zh-CN\nlang-target: vi\n", "ja", "vi", false},
+		{"지운 항목은 기본값으로", "log-level: info\n", "ja", "ko", false},
+		{"지원하지 않는 언어", "lang-target: xx\n", "ja", "ko", true},
+		{"잘못된 번역 플랫폼", "lang-platform: google\n", "ja", "ko", true},
+		{"잘못된 기록 수준", "log-level: loud\n", "ja", "ko", true},
+		{"다시 설정 파일 값 적용", "lang-target: th\n", "ja", "th", false},
+	}
+
+	for _, test := range tests {
+		writeConfig(t, path, test.content)
+
+		if e := reloadConfig(); (e != nil) != test.fail {
+			t.Errorf("%s: 오류 %v", test.name, e)
+		}
+
+		// 실패했다면 이전 설정을 유지해야 함
+		s := currentSettings()
+		if s.Source != test.source || s.Target != test.target {
+			t.Errorf("%s: %s → %s, 기대값 %s → %s", test.name, s.Source, s.Target, test.source, test.target)
+		}
+
+		if *langSource != s.Source || *langTarget != s.Target {
+			t.Errorf("%s: 플래그 %s → %s, 기대값 %s → %s", test.name, *langSource, *langTarget, s.Source, s.Target)
+		}
+	}
+}
+
+func TestRuntimeOverrides(t *testing.T) {
+	path := withConfig(t, map[string]string{"lang-source": "ja"})
+
+	writeConfig(t, path, "lang-target: en\n")
+
+	if e := reloadConfig(); e != nil {
+		t.Fatal(e)
+	}
+
+	// 관리 API 로 바꾼 값은 명령줄과 설정 파일보다 우선해야 함
+	if e := updateSettings(adminSettings{Source: "ko", Target: "vi"}); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, content := range []string{"lang-target: en\n", "lang-source: zh-CN\nlang-target: th\n", ""} {
+		writeConfig(t, path, content)
+
+		if e := reloadConfig(); e != nil {
+			t.Fatal(e)
+		}
+
+		if s := currentSettings(); s.Source != "ko" || s.Target != "vi" {
+			t.Errorf("%q: %s → %s, 기대값 ko → vi", content, s.Source, s.Target)
+		}
+	}
+
+	// 바꾸지 않은 값은 그대로 두고 잘못된 값은 거부해야 함
+	if e := updateSettings(adminSettings{Target: "xx"}); e == nil {
+		t.Error("지원하지 않는 언어로 바꿨습니다")
+	}
+
+	if s := currentSettings(); s.Source != "ko" || s.Target != "vi" || runtimeOverrides["lang-target"] != "vi" {
+		t.Errorf("%s → %s, %v", s.Source, s.Target, runtimeOverrides)
+	}
+}
+
+func TestValidateHosts(t *testing.T) {
+	saved := nico.Hosts
+	t.Cleanup(func() { nico.Hosts = saved })
+
+	tests := []struct {
+		hosts []string
+		fail  bool
+	}{
+		{[]string{"nmsg.nicovideo.jp"}, false},
+		{[]string{"nmsg.nicovideo.jp", "public.nvcomment.nicovideo.jp."}, false},
+		{[]string{"NMSG.NICOVIDEO.JP"}, false},
+		{[]string{"nmsg.nicovideo.jp", "example.com"}, true},
+		{[]string{"evilnicovideo.jp"}, true},
+		{nil, true},
+	}
+
+	for _, test := range tests {
+		nico.Hosts = test.hosts
+
+		if e := validateFlags(); (e != nil) != test.fail {
+			t.Errorf("%v: 오류 %v", test.hosts, e)
+		}
+	}
+}
+
+func TestFlagValueEqual(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Duration("interval", 24*time.Hour, "")
+	fs.Bool("enabled", true, "")
+	fs.String("name", "nicotrans", "")
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"interval", "24h0m0s", true},
+		{"interval", "24h", true},
+		{"interval", "1440m", true},
+		{"interval", "1h", false},
+		{"interval", "하루", false},
+		{"enabled", "true", true},
+		{"enabled", "1", true},
+		{"enabled", "false", false},
+		{"name", "nicotrans", true},
+		{"name", "nicotrans ", false},
+	}
+
+	for _, test := range tests {
+		if got := flagValueEqual(fs.Lookup(test.name), test.value); got != test.want {
+			t.Errorf("%s %q: %v, 기대값 %v", test.name, test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/resolver"
+	"github.com/hype5/nicotrans-go/pkg/translator"
+	"github.com/miekg/dns"
+)
+
+// 상태 확인 하나에 기다릴 시간
+var healthTimeout = 5 * time.Second
+
+// 번역기 확인 결과를 재사용할 시간, 확인할 때마다 번역기를 부르지 않도록 합니다
+var probeInterval = time.Minute
+
+// healthCheck 상태 확인 하나의 결과
+type healthCheck struct {
+	OK         bool    `json:"ok"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// healthReport 상태 확인 결과
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// 마지막 번역기 확인 결과
+var translatorProbe struct {
+	mu      sync.Mutex
+	key     string
+	checked time.Time
+	err     error
+}
+
+// handleHealth 서버가 요청을 처리하고 있는지 확인합니다
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, map[string]func(context.Context) error{
+		"server": checkServer,
+	})
+}
+
+// readinessHandler 인증서, 니코니코 서버 주소 조회, 번역기가 모두 준비됐는지 확인합니다
+func readinessHandler(issuer *certificate.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, r, map[string]func(context.Context) error{
+			"certificate": func(context.Context) error {
+				return checkCertificate(issuer)
+			},
+			"resolver":   checkResolver,
+			"translator": checkTranslator,
+		})
+	}
+}
+
+// initHealth 상태 확인을 대시보드와 별도 주소에서 실행합니다
+// 대시보드를 열지 않아도 서비스 관리자나 로드 밸런서가 상태를 확인할 수 있습니다
+func initHealth(issuer *certificate.Issuer, l net.Listener) {
+	if l == nil {
+		return
+	}
+
+	server := &http.Server{Handler: healthHandler(issuer)}
+	onShutdownServer("상태 확인 종료", server)
+
+	go func() {
+		log.Infof("상태 확인을 실행합니다: http://%s/healthz", *healthListen)
+
+		if e := server.Serve(l); e != http.ErrServerClosed {
+			log.Panic("상태 확인을 여는 중 오류가 발생했습니다\n", e)
+		}
+	}()
+}
+
+// healthHandler /healthz 와 /readyz 를 처리하는 핸들러를 만듭니다
+func healthHandler(issuer *certificate.Issuer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealth)
+	mux.HandleFunc("/readyz", readinessHandler(issuer))
+
+	return mux
+}
+
+// writeHealth 확인을 동시에 실행하고 하나라도 실패하면 503 을 응답합니다
+func writeHealth(w http.ResponseWriter, r *http.Request, checks map[string]func(context.Context) error) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	report := healthReport{Status: "ok", Checks: map[string]healthCheck{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+
+	for name, check := range checks {
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+
+			start := time.Now()
+			e := check(ctx)
+
+			result := healthCheck{OK: e == nil, DurationMS: milliseconds(time.Since(start))}
+			if e != nil {
+				result.Error = e.Error()
+			}
+
+			mu.Lock()
+			report.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, check := range report.Checks {
+		if !check.OK {
+			report.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeJSON(w, status, report)
+}
+
+// checkServer 서버 소켓에 직접 접속해서 응답하는지 확인합니다
+// 커널은 서버가 멈춰도 연결을 받아주기 때문에 TLS 핸드셰이크나 HTTP 응답까지 확인합니다
+func checkServer(ctx context.Context) error {
+	addr := localAddr(serverAddr())
+
+	if *proxyPort > 0 {
+		req, e := http.NewRequest(http.MethodGet, "http://"+addr+"/proxy.pac", nil)
+		if e != nil {
+			return e
+		}
+
+		client := &http.Client{Transport: &http.Transport{Proxy: nil}}
+
+		res, e := client.Do(req.WithContext(ctx))
+		if e != nil {
+			return e
+		}
+
+		defer res.Body.Close()
+		ioutil.ReadAll(res.Body)
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("프록시 서버가 %s 로 응답했습니다", res.Status)
+		}
+
+		return nil
+	}
+
+	dialer := &net.Dialer{}
+
+	conn, e := dialer.DialContext(ctx, "tcp", addr)
+	if e != nil {
+		return e
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// 루트 인증서를 설치하지 않은 환경에서도 확인할 수 있도록 인증서는 검사하지 않기
+	client := tls.Client(conn, &tls.Config{
+		ServerName:         nico.Hosts[0],
+		InsecureSkipVerify: true,
+	})
+
+	return client.Handshake()
+}
+
+// localAddr 모든 주소에서 받는 서버라면 루프백 주소로 바꿉니다
+func localAddr(addr string) string {
+	host, port, e := net.SplitHostPort(addr)
+	if e != nil {
+		return addr
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if ip != nil && ip.To4() == nil {
+			return net.JoinHostPort("::1", port)
+		}
+
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+
+	return addr
+}
+
+// checkCertificate 루트 인증서가 유효한지 확인합니다
+func checkCertificate(issuer *certificate.Issuer) error {
+	ca := issuer.CA()
+	now := time.Now()
+
+	if now.Before(ca.NotBefore) {
+		return fmt.Errorf("인증서가 %s 부터 유효합니다", ca.NotBefore.Format(time.RFC3339))
+	}
+
+	if now.After(ca.NotAfter) {
+		return fmt.Errorf("인증서가 %s 에 만료됐습니다", ca.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// checkResolver 업스트림 DNS 서버가 니코니코 서버 주소에 응답하는지 확인합니다
+// 캐시된 결과로 성공하지 않도록 LookupIP 대신 직접 질의합니다
+func checkResolver(ctx context.Context) error {
+	qtype := dns.TypeA
+	if nico.Resolver.Network == "ip6" {
+		qtype = dns.TypeAAAA
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(nico.Hosts[0]), qtype)
+
+	res, e := nico.Resolver.Exchange(ctx, m)
+	if e != nil {
+		return e
+	}
+
+	if res.Rcode != dns.RcodeSuccess {
+		return &resolver.RcodeError{Name: m.Question[0].Name, Rcode: res.Rcode}
+	}
+
+	if len(res.Answer) == 0 {
+		return fmt.Errorf("%s 주소가 없습니다", nico.Hosts[0])
+	}
+
+	return nil
+}
+
+// checkTranslator 짧은 문장을 번역해서 번역기가 응답하는지 확인합니다
+// 번역 설정이 바뀌지 않았다면 probeInterval 동안 이전 결과를 사용합니다
+func checkTranslator(ctx context.Context) error {
+	settings := currentSettings()
+	key := strings.Join([]string{settings.Platform, settings.Source, settings.Target}, " ")
+
+	translatorProbe.mu.Lock()
+	defer translatorProbe.mu.Unlock()
+
+	if translatorProbe.key == key && time.Since(translatorProbe.checked) < probeInterval {
+		return translatorProbe.err
+	}
+
+	var e error
+
+	translated := translator.Translate(ctx, []string{"§0\n1\n"}, settings.Platform, settings.Source, settings.Target)
+
+	select {
+	case result := <-translated:
+		e = probeError(result)
+	case <-ctx.Done():
+		// 번역이 끝나면 결과를 버리고 다음 확인 때 다시 시도하기
+		go func() { <-translated }()
+
+		return fmt.Errorf("번역기가 응답하지 않습니다: %s", ctx.Err())
+	}
+
+	translatorProbe.key = key
+	translatorProbe.checked = time.Now()
+	translatorProbe.err = e
+
+	return e
+}
+
+// probeError 확인용 번역 결과에 오류가 있는지 확인합니다
+func probeError(result translator.TranslateResult) error {
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var translated strings.Builder
+	for _, seq := range result.Sequences {
+		if seq.Error != nil {
+			return seq.Error
+		}
+
+		translated.WriteString(seq.Translated)
+	}
+
+	if len(queriesPattern.FindAllStringSubmatch(translated.String(), -1)) != 1 {
+		return fmt.Errorf("번역 결과의 형식이 바뀌었습니다: %q", translated.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/resolver"
+	"github.com/miekg/dns"
+)
+
+// startDNS 니코니코 서버 주소로 응답하는 UDP DNS 서버를 시작합니다
+// failing 이 0 이 아니라면 SERVFAIL 로 응답합니다
+func startDNS(t *testing.T, failing *int32) string {
+	t.Helper()
+
+	pc, e := net.ListenPacket("udp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		if atomic.LoadInt32(failing) != 0 {
+			m.Rcode = dns.RcodeServerFailure
+		} else if r.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 203.104.248.135")
+			m.Answer = append(m.Answer, rr)
+		}
+
+		w.WriteMsg(m)
+	})}
+
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestCheckResolver(t *testing.T) {
+	var failing int32
+	addr := startDNS(t, &failing)
+
+	saved := nico.Resolver
+	t.Cleanup(func() { nico.Resolver = saved })
+
+	r, e := resolver.New([]string{addr}, "ip4")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r.Timeout = time.Second
+	nico.Resolver = r
+
+	ctx := context.Background()
+
+	if e := checkResolver(ctx); e != nil {
+		t.Fatal(e)
+	}
+
+	// 캐시에 결과가 있어도 DNS 서버가 실패하면 실패해야 함
+	if _, e := r.LookupIP(ctx, nico.Hosts[0]); e != nil {
+		t.Fatal(e)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+
+	if _, e := r.LookupIP(ctx, nico.Hosts[0]); e != nil {
+		t.Errorf("캐시된 결과를 받지 못했습니다: %v", e)
+	}
+
+	if e := checkResolver(ctx); e == nil {
+		t.Error("DNS 서버가 실패했는데 성공했습니다")
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	handler := healthHandler(nil)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/healthz", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/readyz", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/v1/settings", http.StatusNotFound},
+		{http.MethodGet, "/", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.want {
+			t.Errorf("%s %s: %d, 기대값 %d", test.method, test.path, w.Code, test.want)
+		}
+	}
+}
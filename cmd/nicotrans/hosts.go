@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hype5/nicotrans-go/pkg/hosts"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/system"
+)
+
+// addHostsBlock 니코트랜스 블록에 가로채는 호스트를 모두 추가하고 새로 추가한 호스트를 상태 파일에 기록합니다
+func addHostsBlock(file *hosts.File, missing []string) error {
+	entries := make([]hosts.Entry, len(nico.Hosts))
+	for i, host := range nico.Hosts {
+		entries[i] = hosts.Entry{IP: *serverIP, Host: host}
+	}
+
+	// 종료할 때 지울 블록이라면 비정상 종료를 알 수 있도록 프로세스 기록하기
+	var owner hosts.Process
+	if *hostsCleanup {
+		owner = currentProcess()
+	}
+
+	file.SetBlock(entries, owner)
+
+	if e := file.Save(); e != nil {
+		return e
+	}
+
+	for _, host := range missing {
+		state.addHost(*serverIP, host)
+	}
+
+	if e := state.save(); e != nil {
+		log.Errorf("상태 파일을 저장할 수 없습니다: %s", e)
+	}
+
+	return nil
+}
+
+// setHostsRedirect 실행 중에 호스트 파일의 니코트랜스 블록을 추가하거나 지웁니다
+func setHostsRedirect(enabled bool) error {
+	if *proxyPort > 0 {
+		return fmt.Errorf("프록시 모드에서는 호스트 파일을 사용하지 않습니다")
+	}
+
+	if privilegesDropped {
+		return fmt.Errorf("권한을 내려 실행 중이라 호스트 파일을 수정할 수 없습니다")
+	}
+
+	if socketActivated {
+		return fmt.Errorf("소켓 활성화로 실행 중이라 호스트 파일을 수정하지 않습니다")
+	}
+
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		return e
+	}
+
+	if !enabled {
+		if len(file.Block()) == 0 {
+			return nil
+		}
+
+		if e := removeHostsBlock(file); e != nil {
+			return e
+		}
+
+		log.Info("호스트 파일에 추가한 항목을 지웠습니다")
+
+		return nil
+	}
+
+	var missing []string
+	for _, host := range nico.Hosts {
+		if !file.Has(*serverIP, host) {
+			missing = append(missing, host)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if e := addHostsBlock(file, missing); e != nil {
+		return e
+	}
+
+	log.Info("호스트 파일에 포워딩에 필요한 항목을 추가했습니다")
+
+	return nil
+}
+
+// repairHosts 이전 실행이 비정상 종료되어 남은 호스트 파일 블록을 지웁니다
+func repairHosts() {
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		log.Errorf("호스트 파일을 열 수 없습니다: %s", e)
+		return
+	}
+
+	// 종료할 때 지우지 않는 블록이거나 아직 실행 중인 프로세스의 블록이라면 그대로 두기
+	owner := file.Owner()
+	if owner.PID == 0 || processRunning(owner) {
+		return
+	}
+
+	log.Warningf("비정상 종료된 이전 실행 (pid %d) 이 남긴 호스트 파일 항목을 지웁니다", owner.PID)
+
+	if e := removeHostsBlock(file); e != nil {
+		log.Errorf("호스트 파일 항목을 지울 수 없습니다: %s", e)
+	}
+}
+
+// cleanupHosts 이번 실행에서 추가한 호스트 파일 블록을 지웁니다
+func cleanupHosts() {
+	if !*hostsCleanup || privilegesDropped {
+		return
+	}
+
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		log.Errorf("호스트 파일을 열 수 없습니다: %s", e)
+		return
+	}
+
+	if file.Owner() != currentProcess() {
+		return
+	}
+
+	if e := removeHostsBlock(file); e != nil {
+		log.Errorf("호스트 파일 항목을 지울 수 없습니다: %s", e)
+		return
+	}
+
+	log.Info("호스트 파일에 추가한 항목을 지웠습니다")
+}
+
+// removeHostsBlock 니코트랜스 블록을 지우고 상태 파일에서도 지웁니다
+func removeHostsBlock(file *hosts.File) error {
+	entries := file.Block()
+
+	file.SetBlock(nil, hosts.Process{})
+
+	if e := file.Save(); e != nil {
+		return e
+	}
+
+	for _, entry := range entries {
+		state.removeHost(entry.IP, entry.Host)
+	}
+
+	return state.save()
+}
+
+// currentProcess 현재 프로세스를 반환합니다
+func currentProcess() hosts.Process {
+	process := hosts.Process{PID: os.Getpid()}
+
+	if start, e := system.ProcessStart(process.PID); e == nil {
+		process.Start = start
+	}
+
+	return process
+}
+
+// processRunning 블록을 추가한 프로세스가 아직 실행 중인지?
+// 같은 아이디를 다시 사용한 다른 프로세스를 구분하기 위해 시작 시점도 비교합니다
+func processRunning(owner hosts.Process) bool {
+	if !system.ProcessExists(owner.PID) {
+		return false
+	}
+
+	// 시작 시점을 기록하지 않은 이전 버전의 블록은 아이디만 비교
+	if owner.Start == "" {
+		return true
+	}
+
+	start, e := system.ProcessStart(owner.PID)
+	if e != nil {
+		// 확인할 수 없다면 실행 중인 프로세스의 블록을 지우지 않도록 실행 중으로 취급
+		return true
+	}
+
+	return start == owner.Start
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/translator"
+)
+
+// 클라이언트가 번역될 언어를 고를 때 사용하는 쿠키와 쿼리 이름
+const (
+	languageCookie = "nicotrans-lang"
+	languageParam  = "lang"
+)
+
+// 번역될 언어를 고르는 설정 페이지 경로
+const settingsPath = "/nicotrans/"
+
+// clientLanguage 설정 파일에서 지정한 클라이언트 아이피 대역별 번역될 언어
+type clientLanguage struct {
+	Network  *net.IPNet
+	Language string
+}
+
+// parseClients 아이피나 CIDR 대역과 언어 목록을 불러옵니다
+// 여러 대역에 포함되는 아이피는 가장 좁은 대역의 언어를 사용하도록 정렬합니다
+func parseClients(platform string, clients map[string]string) ([]clientLanguage, error) {
+	var list []clientLanguage
+
+	for addr, language := range clients {
+		if !translator.IsLanguage(platform, language) {
+			return nil, fmt.Errorf("clients 의 %s 값 %s 은 %s 에서 사용할 수 없는 언어입니다", addr, language, platform)
+		}
+
+		network, e := parseNetwork(addr)
+		if e != nil {
+			return nil, fmt.Errorf("clients 의 %s 은 아이피나 CIDR 대역이 아닙니다", addr)
+		}
+
+		list = append(list, clientLanguage{Network: network, Language: language})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := list[i].Network.Mask.Size()
+		b, _ := list[j].Network.Mask.Size()
+
+		return a > b
+	})
+
+	return list, nil
+}
+
+func parseNetwork(addr string) (*net.IPNet, error) {
+	if strings.Contains(addr, "/") {
+		_, network, e := net.ParseCIDR(addr)
+		return network, e
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("%s 은 아이피가 아닙니다", addr)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// targetLanguage 요청한 클라이언트의 번역될 언어를 반환합니다
+// 쿼리, 쿠키, 설정 파일의 아이피 대역, 기본 언어 순서로 사용합니다
+func (s *translateSettings) targetLanguage(r *http.Request) string {
+	if language := r.URL.Query().Get(languageParam); translator.IsLanguage(s.Platform, language) {
+		return language
+	}
+
+	if cookie, e := r.Cookie(languageCookie); e == nil && translator.IsLanguage(s.Platform, cookie.Value) {
+		return cookie.Value
+	}
+
+	host, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, client := range s.Clients {
+			if client.Network.Contains(ip) {
+				return client.Language
+			}
+		}
+	}
+
+	return s.Target
+}
+
+// handleSettings 번역될 언어를 고르는 페이지를 응답하고 고른 언어를 쿠키에 저장합니다
+func handleSettings(w http.ResponseWriter, r *http.Request) {
+	settings := currentSettings()
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		language := r.PostFormValue(languageParam)
+		if language != "" && !translator.IsLanguage(settings.Platform, language) {
+			http.Error(w, fmt.Sprintf("%s 은 사용할 수 없는 언어입니다", language), http.StatusBadRequest)
+			return
+		}
+
+		cookie := &http.Cookie{
+			Name:     languageCookie,
+			Value:    language,
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+		}
+
+		// 니코니코 플레이어는 다른 사이트에서 요청하기 때문에 SameSite=None 이어야 쿠키가 전달됨
+		if cookie.Secure {
+			cookie.SameSite = http.SameSiteNoneMode
+		}
+
+		// 비어있다면 기본 언어 사용하기
+		if language == "" {
+			cookie.Expires = time.Unix(0, 0)
+			cookie.MaxAge = -1
+		}
+
+		http.SetCookie(w, cookie)
+		http.Redirect(w, r, settingsPath, http.StatusSeeOther)
+
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	selected := ""
+	if cookie, e := r.Cookie(languageCookie); e == nil && translator.IsLanguage(settings.Platform, cookie.Value) {
+		selected = cookie.Value
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	e := settingsTemplate.Execute(w, map[string]interface{}{
+		"Platform":  settings.Platform,
+		"Source":    settings.Source,
+		"Default":   settings.Target,
+		"Current":   settings.targetLanguage(r),
+		"Selected":  selected,
+		"Languages": translator.Languages(settings.Platform),
+		"Param":     languageParam,
+	})
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+	}
+}
+
+var settingsTemplate = template.Must(template.New("settings").Parse(`<!DOCTYPE html>
+<html lang="ko">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>니코트랜스 언어 설정</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; line-height: 1.6; }
+</style>
+</head>
+<body>
+<h1>니코트랜스 언어 설정</h1>
+
+<p>{{.Platform}} 번역기로 {{.Source}} 코멘트를 번역합니다. 현재 이 기기는 <b>{{.Current}}</b> 로 번역됩니다.</p>
+
+<form method="post">
+<select name="{{.Param}}">
+<option value=""{{if not .Selected}} selected{{end}}>기본 언어 ({{.Default}})</option>
+{{- range .Languages}}
+<option value="{{.}}"{{if eq . $.Selected}} selected{{end}}>{{.}}</option>
+{{- end}}
+</select>
+<button type="submit">저장</button>
+</form>
+
+<p>선택한 언어는 이 브라우저의 쿠키에 저장됩니다.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/logfile"
+	"github.com/op/go-logging"
+)
+
+var logTextFormat = logging.MustStringFormatter(
+	`%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s}%{color:reset} %{message}`,
+)
+
+// 파일에 쓸 때는 색을 넣지 않기
+var logFileFormat = logging.MustStringFormatter(
+	`%{time:2006-01-02T15:04:05.000Z07:00} %{shortfunc} ▶ %{level:.4s} %{message}`,
+)
+
+// 수준을 바꿀 수 있는 기록 백엔드
+var logBackend logging.LeveledBackend
+
+// initLogging 기록 수준, 형식, 출력할 곳을 설정합니다
+// 권한을 내린 자식 프로세스를 감시하는 부모 프로세스는 기록 파일을 관리자 소유로 만들지 않도록 표준 출력에 씁니다
+func initLogging() error {
+	level, e := parseLogLevel(*logLevel)
+	if e != nil {
+		return e
+	}
+
+	path := *logPath
+	if supervisor() {
+		path = ""
+	}
+
+	var out io.Writer = os.Stdout
+	if path != "" {
+		file, e := logfile.Open(path, int64(*logMaxSize)*1024*1024, *logMaxBackups)
+		if e != nil {
+			return fmt.Errorf("기록 파일을 열 수 없습니다: %s", e)
+		}
+
+		out = file
+	}
+
+	var formatter logging.Formatter
+	switch {
+	case *logFormat == "json":
+		formatter = jsonFormatter{}
+	case *logFormat != "text":
+		return fmt.Errorf("log-format 값은 text, json 중 하나여야 합니다: %s", *logFormat)
+	case path != "":
+		formatter = logFileFormat
+	default:
+		formatter = logTextFormat
+	}
+
+	backend := logging.NewBackendFormatter(logging.NewLogBackend(out, "", 0), formatter)
+
+	logBackend = logging.AddModuleLevel(backend)
+	logBackend.SetLevel(level, "")
+	logging.SetBackend(logBackend)
+
+	return nil
+}
+
+// parseLogLevel 기록 수준 이름을 읽습니다
+func parseLogLevel(name string) (logging.Level, error) {
+	level, e := logging.LogLevel(name)
+	if e != nil {
+		return 0, fmt.Errorf("log-level 값은 debug, info, notice, warning, error, critical 중 하나여야 합니다: %s", name)
+	}
+
+	return level, nil
+}
+
+// setLogLevel 실행 중에 기록 수준을 바꿉니다
+func setLogLevel(level logging.Level) {
+	if logBackend != nil {
+		logBackend.SetLevel(level, "")
+	}
+}
+
+// logFields 기록에 붙일 항목, 이름과 값을 번갈아 적습니다
+// 텍스트 형식은 메세지 뒤에 이름=값 으로 붙이고 JSON 형식은 각각 항목으로 씁니다
+// 형식 문자열이 없는 log.Info, log.Error 등의 마지막 인자로 넘겨야 합니다
+type logFields []interface{}
+
+// with 항목을 덧붙인 새 목록을 반환합니다
+func (f logFields) with(fields ...interface{}) logFields {
+	return append(append(logFields{}, f...), fields...)
+}
+
+func (f logFields) String() string {
+	var b strings.Builder
+
+	for i := 0; i+1 < len(f); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		fmt.Fprintf(&b, "%v=%s", f[i], formatFieldValue(f[i+1]))
+	}
+
+	return b.String()
+}
+
+func formatFieldValue(v interface{}) string {
+	var s string
+
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// jsonFormatter 기록을 한 줄에 하나씩 JSON 으로 씁니다
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	entry := map[string]interface{}{}
+
+	message := r.Message()
+
+	if n := len(r.Args); n > 0 {
+		if fields, ok := r.Args[n-1].(logFields); ok {
+			for i := 0; i+1 < len(fields); i += 2 {
+				value := fields[i+1]
+				if e, ok := value.(error); ok {
+					value = e.Error()
+				}
+
+				entry[fmt.Sprint(fields[i])] = value
+			}
+
+			message = strings.TrimSuffix(strings.TrimSuffix(message, fields.String()), " ")
+		}
+	}
+
+	entry["time"] = r.Time.Format(time.RFC3339Nano)
+	entry["level"] = strings.ToLower(r.Level.String())
+	entry["module"] = r.Module
+	entry["message"] = message
+
+	data, e := json.Marshal(entry)
+	if e != nil {
+		return e
+	}
+
+	_, e = w.Write(data)
+
+	return e
+}
+
+// requestFields 처리한 요청을 기록할 항목
+func requestFields(record requestRecord) logFields {
+	return logFields{
+		"request_id", record.RequestID,
+		"trace_id", record.TraceID,
+		"client", record.Client,
+		"path", record.Path,
+		"status", record.Status,
+		"thread", record.Thread,
+		"comments", record.Comments,
+		"cached", record.Cached,
+		"translated", record.Translated,
+		"backend", record.Backend,
+		"language", record.Language,
+		"duration_ms", record.DurationMS,
+		"upstream_ms", record.UpstreamMS,
+		"translate_ms", record.TranslateMS,
+		"encode_ms", record.EncodeMS,
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestInitLoggingSupervisor(t *testing.T) {
+	savedPath, savedUser, savedDropped, savedBackend := *logPath, *runUser, privilegesDropped, logBackend
+
+	t.Cleanup(func() {
+		*logPath, *runUser, privilegesDropped, logBackend = savedPath, savedUser, savedDropped, savedBackend
+
+		if savedBackend != nil {
+			logging.SetBackend(savedBackend)
+		} else {
+			logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+		}
+	})
+
+	tests := []struct {
+		name    string
+		user    string
+		dropped bool
+		want    bool
+	}{
+		{"권한을 내리지 않음", "", false, true},
+		{"자식 프로세스를 감시하는 부모 프로세스", "nobody", false, false},
+		{"권한을 내린 자식 프로세스", "nobody", true, true},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "nicotrans.log")
+		*logPath, *runUser, privilegesDropped = path, test.user, test.dropped
+
+		if e := initLogging(); e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+
+		// 부모 프로세스가 관리자 소유로 기록 파일을 만들면 자식 프로세스가 열 수 없음
+		if _, e := os.Stat(path); (e == nil) != test.want {
+			t.Errorf("%s: 기록 파일 %v, 기대값 %v", test.name, e == nil, test.want)
+		}
+	}
+}
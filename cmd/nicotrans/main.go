@@ -2,266 +2,842 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"flag"
 	"fmt"
-	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
-	"gitea.chriswiegman.com/chriswiegman/goodhosts"
 	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/hosts"
 	"github.com/hype5/nicotrans-go/pkg/nico"
+	"github.com/hype5/nicotrans-go/pkg/onboard"
+	"github.com/hype5/nicotrans-go/pkg/proxy"
+	"github.com/hype5/nicotrans-go/pkg/resolver"
 	"github.com/hype5/nicotrans-go/pkg/system"
+	"github.com/hype5/nicotrans-go/pkg/trace"
 	"github.com/hype5/nicotrans-go/pkg/translator"
 	"github.com/op/go-logging"
 )
 
 var serverIP = flag.String("ip", "127.0.0.1", "서버 주소")
 var serverPort = flag.Int("port", 443, "서버 포트")
-var certPath = flag.String("cert", "server.crt", "서버 SSL 인증서 경로")
-var certPrivPath = flag.String("cert-privatekey", "server.key", "서버 SSL 인증서 키 경로")
-var certCreate = flag.Bool("cert-create", true, "서버 SSL 인증서가 존재하지 않을 때 생성할지?")
-var certInstall = flag.Bool("cert-install", true, "서버 SSL 인증서를 설치할지?")
+var proxyPort = flag.Int("proxy-port", 0, "HTTP 프록시 모드로 실행할 포트, 0 이면 호스트 파일 모드로 실행")
+var proxyAllow = flag.String("proxy-allow", "", "HTTP 프록시와 내장 DNS 서버를 사용할 수 있는 클라이언트 아이피나 CIDR 대역 (쉼표로 구분), 비어있으면 프록시는 루프백 주소만, DNS 서버는 루프백과 사설 대역만 허용")
+var certPath = flag.String("cert", "server.crt", "루트 인증서 경로")
+var certPrivPath = flag.String("cert-privatekey", "server.key", "루트 인증서 키 경로")
+var certCreate = flag.Bool("cert-create", true, "루트 인증서가 존재하지 않을 때 생성할지?")
+var certInstall = flag.Bool("cert-install", true, "루트 인증서를 설치할지?")
+var certRenewBefore = flag.Duration("cert-renew-before", 30*24*time.Hour, "루트 인증서 만료까지 이 기간보다 적게 남으면 새로 만들기")
+var certCheckInterval = flag.Duration("cert-check-interval", time.Hour, "루트 인증서 만료를 확인할 주기")
+var certInstallDryRun = flag.Bool("cert-install-dry-run", false, "루트 인증서를 설치하지 않고 바뀔 내용만 출력할지?")
+var certKeyAlgorithm = flag.String("cert-key-algorithm", "rsa", "새 루트 인증서의 키 알고리즘 (rsa, ecdsa)")
+var certKeyBits = flag.Int("cert-key-bits", 0, "새 루트 인증서의 키 크기, 0 이면 알고리즘의 기본 크기 사용")
+var certKeyPKCS8 = flag.Bool("cert-key-pkcs8", false, "루트 인증서 키를 PKCS#8 형식으로 저장할지?")
+var certExportDER = flag.String("cert-export-der", "", "다른 기기에 설치할 루트 인증서를 DER 형식으로 저장할 경로, 비어있으면 저장하지 않음")
+var certExportPKCS12 = flag.String("cert-export-p12", "", "다른 기기에 설치할 루트 인증서를 키 없이 PKCS#12 형식으로 저장할 경로, 비어있으면 저장하지 않음")
+
+var runUser = flag.String("user", "", "소켓을 연 뒤 이 사용자로 권한을 내려 실행 (리눅스 전용)")
+var runGroup = flag.String("group", "", "권한을 내릴 때 사용할 그룹, 비어있으면 사용자의 기본 그룹 사용")
+
+var statePath = flag.String("state", "nicotrans.state.json", "설치한 인증서와 호스트 항목을 기록할 파일 경로")
 
 var hostsEdit = flag.Bool("hosts-edit", true, "호스트 파일에 자동으로 아이피를 추가할지?")
+var hostsPath = flag.String("hosts-path", hosts.DefaultPath(), "호스트 파일 경로")
+var hostsCleanup = flag.Bool("hosts-cleanup", true, "종료할 때 호스트 파일에 추가한 항목을 지울지?")
+
+var dnsServers = flag.String("dns", "1.1.1.1", "니코니코 서버 주소를 조회할 DNS 서버 (쉼표로 구분, tcp:// tls:// https:// 사용 가능)")
+var dnsNetwork = flag.String("dns-network", "ip", "조회할 주소 종류 (ip, ip4, ip6)")
+var dnsListen = flag.String("dns-listen", "", "내장 DNS 서버 주소 (예: :53), 비어있으면 사용하지 않음")
+
+var onboardListen = flag.String("onboard-listen", "", "다른 기기에 루트 인증서를 설치할 수 있는 HTTP 안내 페이지 주소 (예: :8080), 비어있으면 사용하지 않음")
+var adminListen = flag.String("admin-listen", "", "대시보드와 관리 API 주소 (예: 127.0.0.1:8081), 비어있으면 사용하지 않음")
+var healthListen = flag.String("health-listen", "", "토큰 없이 /healthz, /readyz 만 응답하는 상태 확인 주소 (예: 127.0.0.1:8082), 비어있으면 사용하지 않음")
+var adminTokenPath = flag.String("admin-token-file", "nicotrans.admin.token", "관리 API 토큰 파일 경로, 없으면 새로 만듭니다 (NICOTRANS_ADMIN_TOKEN 환경 변수가 우선)")
+
+var configPath = flag.String("config", "", "설정 파일 경로 (YAML), 명령줄에서 지정한 플래그가 우선합니다")
+var glossaryPath = flag.String("glossary", "", "번역하기 전에 바꿀 단어 목록 파일 경로 (한 줄에 하나씩 원문=바꿀 단어)")
 
 var langPlatform = flag.String("lang-platform", "papago", "사용될 번역기 종류")
 var langSource = flag.String("lang-source", "ja", "번역할 언어 2자리 코드")
 var langTarget = flag.String("lang-target", "ko", "번역될 언어 2자리 코드")
+var cacheSize = flag.Int("cache-size", 10000, "번역한 코멘트를 기억할 개수, 0 이면 기억하지 않음")
+var cacheFile = flag.String("cache-file", "", "종료할 때 번역 캐시를 저장하고 실행할 때 불러올 파일 경로, 비어있으면 저장하지 않음")
+
+var shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "종료할 때 처리 중인 요청이 끝나기를 기다릴 시간")
+
+var logLevel = flag.String("log-level", "info", "기록할 최소 수준 (debug, info, notice, warning, error, critical)")
+var logFormat = flag.String("log-format", "text", "기록 형식 (text, json)")
+var logPath = flag.String("log-file", "", "기록을 저장할 파일 경로, 비어있으면 표준 출력에 씁니다")
+var logMaxSize = flag.Int("log-max-size", 10, "기록 파일이 이 크기(MB)를 넘으면 이전 파일로 옮기고 새 파일에 쓰기, 0 이면 옮기지 않음")
+var logMaxBackups = flag.Int("log-max-backups", 5, "보관할 이전 기록 파일 수")
+
+var otlpEndpoint = flag.String("otlp-endpoint", "", "트레이스를 보낼 OTLP/HTTP 수집기 주소 (예: http://127.0.0.1:4318), 비어있으면 보내지 않음")
+var otlpInterval = flag.Duration("otlp-interval", 5*time.Second, "모아둔 트레이스를 수집기로 보낼 주기")
+
+// 루트 인증서 키 비밀번호를 읽을 환경 변수
+const keyPasswordEnv = "NICOTRANS_KEY_PASSWORD"
 
 var log = logging.MustGetLogger("nicotrans")
-var logFormat = logging.MustStringFormatter(
-	`%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s}%{color:reset} %{message}`,
-)
 
-var certificateTemplate = &x509.Certificate{
-	SerialNumber: new(big.Int).SetInt64(int64(time.Now().Year())),
-	Subject: pkix.Name{
-		Organization: []string{"NicoTrans"},
-	},
-	DNSNames:    []string{"nmsg.nicovideo.jp"},
-	NotBefore:   time.Now(),
-	NotAfter:    time.Now().AddDate(10, 0, 0),
-	KeyUsage:    x509.KeyUsageDigitalSignature,
-	ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-	IsCA:        true,
+// newCertificateTemplate 루트 인증서 템플릿을 만듭니다
+// 호스트별 인증서는 접속할 때마다 이 인증서로 발급합니다
+// 키가 유출되더라도 다른 사이트의 인증서를 만들 수 없도록 니코니코 도메인만 허용하는 이름 제약 조건을 넣습니다
+// 호스트 하나하나가 아니라 도메인을 허용하기 때문에 가로챌 호스트가 늘어나도 다시 설치할 필요가 없습니다
+func newCertificateTemplate() *x509.Certificate {
+	_, allIPv4, _ := net.ParseCIDR("0.0.0.0/0")
+	_, allIPv6, _ := net.ParseCIDR("::/0")
+
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: []string{"NicoTrans"},
+			CommonName:   "NicoTrans Root CA",
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         append([]string{}, nico.Domains...),
+		ExcludedIPRanges:            []*net.IPNet{allIPv4, allIPv6},
+	}
 }
 
+// 설치한 항목 기록
+var state *installState
+
+// 번역한 코멘트 캐시
+var cache *translator.Cache
+
+// 코멘트 API 응답 형식
+const jsonContentType = "application/json"
+
 var queriesPattern = regexp.MustCompile(`(?m)^§(\d+)\n([^§]+)`)
 
 func initHosts() error {
-	if *hostsEdit && runtime.GOOS == "windows" {
-		log.Info("호스트 파일을 확인합니다")
+	if !*hostsEdit {
+		return nil
+	}
 
-		hosts, e := goodhosts.NewHosts()
-		if e != nil {
-			return fmt.Errorf("호스트 파일을 열 수 없습니다: %s", e)
+	log.Info("호스트 파일을 확인합니다")
+
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		return fmt.Errorf("호스트 파일을 열 수 없습니다: %s", e)
+	}
+
+	var missing []string
+	for _, host := range nico.Hosts {
+		if !file.Has(*serverIP, host) {
+			missing = append(missing, host)
 		}
+	}
 
-		if hosts.Has(*serverIP, "nmsg.nicovideo.jp") {
-			log.Info("호스트 파일에 포워딩에 필요한 항목이 존재합니다")
-		} else {
-			log.Info("호스트 파일에 포워딩에 필요한 항목이 존재하지 않습니다")
+	if len(missing) == 0 {
+		log.Info("호스트 파일에 포워딩에 필요한 항목이 존재합니다")
+		return nil
+	}
 
-			r, e := system.HasRoot()
-			if e != nil {
-				log.Errorf("사용자 권한 정보를 불러오는데 실패했습니다: %s", e)
-			} else if r {
-				// 관리자 권한으로 실행했다면 호스트 수정하기
-				hosts.Add(*serverIP, "nmsg.nicovideo.jp")
+	log.Info("호스트 파일에 포워딩에 필요한 항목이 존재하지 않습니다")
 
-				if e := hosts.Flush(); e != nil {
-					return fmt.Errorf("호스트 파일을 저장할 수 없습니다: %s", e)
-				}
+	if e := addHostsBlock(file, missing); os.IsPermission(e) {
+		return elevate("호스트 파일 수정")
+	} else if e != nil {
+		return fmt.Errorf("호스트 파일을 저장할 수 없습니다: %s", e)
+	}
 
-				log.Info("호스트 파일에 포워딩에 필요한 항목을 추가했습니다")
-			} else {
-				log.Info("호스트 파일 수정을 위해 관리자 권한 취득을 시도합니다")
+	log.Info("호스트 파일에 포워딩에 필요한 항목을 추가했습니다")
 
-				if e := system.RunMeElevated(); e != nil {
-					// 관리자 권한 취득 실패
-					return fmt.Errorf("호스트 파일 수정을 위한 관리자 권한 취득에 실패했습니다: %s", e)
-				}
+	return nil
+}
 
-				os.Exit(0)
-			}
+// initPrivileges 서버 포트를 열거나 권한을 내릴 권한이 없다면 관리자 권한으로 다시 실행합니다
+func initPrivileges(port int) error {
+	if *runUser != "" {
+		if r, e := system.HasRoot(); e == nil && !r {
+			return elevate("다른 사용자로 권한 내리기")
 		}
 	}
 
+	// 물려받은 소켓을 사용한다면 포트를 열 필요 없음
+	if inheritedFile(socketServer) != nil {
+		return nil
+	}
+
+	ok, e := system.CanBind(port)
+	if e != nil {
+		log.Errorf("사용자 권한 정보를 불러오는데 실패했습니다: %s", e)
+		return nil
+	}
+
+	if !ok {
+		return elevate(fmt.Sprintf("%d 포트 열기", port))
+	}
+
 	return nil
 }
 
-func initCertificate() (*x509.Certificate, interface{}, error) {
-	cert, priv, e := certificate.Import(*certPath, *certPrivPath)
+// elevate 관리자 권한으로 다시 실행합니다, 성공하면 현재 프로세스는 종료됩니다
+func elevate(reason string) error {
+	if r, e := system.HasRoot(); e == nil && r {
+		return fmt.Errorf("관리자 권한이 있지만 실패했습니다: %s", reason)
+	}
+
+	log.Infof("관리자 권한 취득을 시도합니다: %s", reason)
+
+	if e := system.RunMeElevated(); e != nil {
+		// 관리자 권한 취득 실패
+		return fmt.Errorf("관리자 권한 취득에 실패했습니다: %s: %s", reason, e)
+	}
+
+	os.Exit(0)
+
+	return nil
+}
+
+func initResolver() error {
+	r, e := resolver.New(strings.Split(*dnsServers, ","), *dnsNetwork)
 	if e != nil {
-		log.Errorf("인증서를 불러올 수 없습니다: %s", e)
+		return fmt.Errorf("DNS 설정이 잘못됐습니다: %s", e)
+	}
 
-		if *certCreate {
-			log.Info("새 인증서를 생성합니다")
+	nico.Resolver = r
 
-			// 새 인증서 만들기
-			if cert, priv, e = certificate.Create(certificateTemplate); e != nil {
-				return nil, nil, fmt.Errorf("인증서를 생성할 수 없습니다: %s", e)
-			}
+	return nil
+}
 
-			// 새로 만든 인증서 파일로 저장하기
-			if e := certificate.Export(cert, priv, *certPath, *certPrivPath); e != nil {
-				return nil, nil, fmt.Errorf("인증서를 저장할 수 없습니다: %s", e)
-			}
-		} else {
-			return nil, nil, fmt.Errorf("인증서가 없으면 서버를 실행할 수 없습니다")
+// initDNSServer 내장 DNS 서버를 실행합니다
+// 다른 기기에서 사용하는 서버라 공개 재귀 DNS 서버가 되지 않도록 proxy-allow 대역이나 사설 대역의 클라이언트만 허용합니다
+func initDNSServer(pc net.PacketConn, l net.Listener) error {
+	if pc == nil {
+		return nil
+	}
+
+	ip := net.ParseIP(*serverIP)
+	if ip == nil {
+		log.Errorf("%s 값은 DNS 서버가 응답할 수 있는 아이피가 아닙니다", *serverIP)
+		return nil
+	}
+
+	if ip.IsLoopback() {
+		log.Info("서버 주소가 루프백 주소라서 다른 기기에서는 DNS 서버를 사용할 수 없습니다")
+	}
+
+	clients, e := proxyClients()
+	if e != nil {
+		return e
+	}
+
+	server := resolver.NewServer(*dnsListen, nico.Hosts, ip, nico.Resolver)
+	server.Clients = clients
+
+	onShutdown("DNS 서버 종료", func(context.Context) error {
+		server.Shutdown()
+		return nil
+	})
+
+	go func() {
+		log.Infof("DNS 서버를 실행합니다: %s", *dnsListen)
+
+		// 다른 고루틴에서 패닉을 일으키면 종료 작업을 실행하지 못하니 직접 종료하기
+		if e := server.Serve(pc, l); e != nil {
+			log.Errorf("DNS 서버를 실행하는 중 오류가 발생했습니다: %s", e)
+
+			shutdown()
+			os.Exit(1)
 		}
+	}()
+
+	return nil
+}
+
+func initOnboarding(issuer *certificate.Issuer, l net.Listener) {
+	if l == nil {
+		return
 	}
 
-	if *certInstall && runtime.GOOS == "windows" {
-		log.Info("인증서 설치를 시도합니다")
+	log.Infof("루트 인증서 SHA-256 지문: %s", onboard.Fingerprint(issuer.CA()))
+
+	server := &http.Server{Handler: onboard.Handler(issuer.CA)}
+	onShutdownServer("인증서 설치 안내 페이지 종료", server)
+
+	go func() {
+		log.Infof("인증서 설치 안내 페이지를 실행합니다: http://%s/", *onboardListen)
 
-		if exists, e := certificate.InstallAsRootCA(cert); e == nil {
-			if exists {
-				log.Info("인증서가 이미 설치되어있습니다")
-			} else {
-				msg := []string{
-					"인증서를 성공적으로 설치했습니다",
-					"\t브라우저가 열려있을 때 인증서를 설치하면 캐시로 인해 인식되지 않을 수 있습니다",
-					"\t코멘트가 보이지 않는다면 열린 브라우저 창을 모두 닫고 다시 열어주세요",
-				}
-				log.Info(strings.Join(msg, "\n"))
+		if e := server.Serve(l); e != http.ErrServerClosed {
+			log.Panic("인증서 설치 안내 페이지를 여는 중 오류가 발생했습니다\n", e)
+		}
+	}()
+}
+
+func initCertificate() (*x509.Certificate, interface{}, error) {
+	cert, priv, e := certificate.ImportWithPassword(*certPath, *certPrivPath, keyPassword())
+	if e == nil {
+		// 불러온 인증서 검사하기
+		// 가로챌 호스트만 허용하는 이전 루트 인증서는 새 호스트를 발급할 수 없으니 한 번 새로 만들기
+		if problems := certificate.Validate(cert, priv, nico.Domains); len(problems) > 0 {
+			for _, problem := range problems {
+				log.Errorf("인증서에 문제가 있습니다: %s", problem)
 			}
-		} else {
-			return nil, nil, fmt.Errorf("인증서를 설치할 수 없습니다: %s", e)
+
+			e = problems[0]
+		} else if certificate.ExpiresWithin(cert, *certRenewBefore) {
+			e = fmt.Errorf("인증서가 곧 만료됩니다: %s", cert.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	if e != nil {
+		log.Errorf("인증서를 사용할 수 없습니다: %s", e)
+
+		if !*certCreate {
+			return nil, nil, fmt.Errorf("인증서가 없으면 서버를 실행할 수 없습니다")
+		}
+
+		if cert, priv, e = createCertificate(); e != nil {
+			return nil, nil, e
 		}
 	}
 
+	if *certInstallDryRun {
+		printInstallPlan(cert)
+		os.Exit(0)
+	}
+
+	exportCertificate(cert)
+
+	if *certInstall && socketActivated {
+		log.Info("소켓 활성화로 실행해 인증서를 설치하지 않습니다, 인증서가 설치되지 않았다면 직접 설치해야 합니다")
+	} else if *certInstall {
+		installCertificate(cert)
+	}
+
+	return cert, priv, nil
+}
+
+// createCertificate 새 루트 인증서를 만들어 파일로 저장합니다
+func createCertificate() (*x509.Certificate, interface{}, error) {
+	log.Info("새 인증서를 생성합니다")
+
+	key, e := certificate.GenerateKey(*certKeyAlgorithm, *certKeyBits)
+	if e != nil {
+		return nil, nil, fmt.Errorf("키를 생성할 수 없습니다: %s", e)
+	}
+
+	cert, priv, e := certificate.CreateWithKey(newCertificateTemplate(), key)
+	if e != nil {
+		return nil, nil, fmt.Errorf("인증서를 생성할 수 없습니다: %s", e)
+	}
+
+	// 새로 만든 인증서 파일로 저장하기
+	if *certInstallDryRun {
+		fmt.Printf("%s, %s 파일에 새 인증서를 저장합니다\n", *certPath, *certPrivPath)
+	} else if e := certificate.ExportWithOptions(cert, priv, *certPath, *certPrivPath, certificate.ExportOptions{
+		PKCS8:    *certKeyPKCS8,
+		Password: keyPassword(),
+	}); e != nil {
+		return nil, nil, fmt.Errorf("인증서를 저장할 수 없습니다: %s", e)
+	}
+
 	return cert, priv, nil
 }
 
+// exportCertificate 다른 기기에서 사용할 수 있도록 루트 인증서를 추가 형식으로 저장합니다
+func exportCertificate(cert *x509.Certificate) {
+	if *certExportDER != "" {
+		if e := certificate.ExportDER(cert, *certExportDER); e != nil {
+			log.Errorf("DER 인증서를 저장할 수 없습니다: %s", e)
+		}
+	}
+
+	if *certExportPKCS12 != "" {
+		if e := certificate.ExportPKCS12(cert, *certExportPKCS12); e != nil {
+			log.Errorf("PKCS#12 인증서를 저장할 수 없습니다: %s", e)
+		}
+	}
+}
+
+// keyPassword 루트 인증서 키 비밀번호를 환경 변수에서 읽습니다, 설정되지 않았다면 암호화하지 않습니다
+func keyPassword() []byte {
+	if password := os.Getenv(keyPasswordEnv); password != "" {
+		return []byte(password)
+	}
+
+	return nil
+}
+
+// installCertificate 루트 인증서를 설치하고 설치한 저장소를 기록합니다
+func installCertificate(cert *x509.Certificate) {
+	log.Info("인증서 설치를 시도합니다")
+
+	installed, e := certificate.InstallAsRootCA(cert)
+
+	for _, store := range installed {
+		state.addCertificate(store.Name(), cert.Raw)
+	}
+
+	if len(installed) > 0 {
+		if e := state.save(); e != nil {
+			log.Errorf("상태 파일을 저장할 수 없습니다: %s", e)
+		}
+	}
+
+	if e != nil {
+		// 인증서는 직접 설치할 수도 있으니 서버는 계속 실행하기
+		log.Errorf("인증서를 설치할 수 없습니다: %s", e)
+	} else if len(installed) == 0 {
+		log.Info("인증서가 이미 설치되어있습니다")
+	} else {
+		msg := []string{
+			"인증서를 성공적으로 설치했습니다",
+			"\t브라우저가 열려있을 때 인증서를 설치하면 캐시로 인해 인식되지 않을 수 있습니다",
+			"\t코멘트가 보이지 않는다면 열린 브라우저 창을 모두 닫고 다시 열어주세요",
+		}
+		log.Info(strings.Join(msg, "\n"))
+	}
+}
+
+// printInstallPlan 인증서를 설치할 때 바뀔 내용을 출력합니다
+func printInstallPlan(cert *x509.Certificate) {
+	stores := certificate.Stores()
+	if len(stores) == 0 {
+		fmt.Println("인증서를 설치할 수 있는 저장소가 없습니다")
+		return
+	}
+
+	for _, store := range stores {
+		if exists, e := store.Contains(cert); e != nil {
+			fmt.Printf("[%s] 설치 여부를 확인할 수 없습니다: %s\n", store.Name(), e)
+			continue
+		} else if exists {
+			fmt.Printf("[%s] 이미 설치되어있습니다\n", store.Name())
+			continue
+		}
+
+		for _, line := range store.Plan(cert) {
+			fmt.Printf("[%s] %s\n", store.Name(), line)
+		}
+	}
+}
+
 func handle(w http.ResponseWriter, r *http.Request) {
 	var e error
 	var status = http.StatusOK
-	var prefix = fmt.Sprintf("%s - %s - %s", r.RemoteAddr, r.URL.Path, r.Referer())
+	var record = requestRecordFrom(r.Context())
+	var fields = logFields{"request_id", record.RequestID, "trace_id", record.TraceID}
+	var ctx = r.Context()
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	defer r.Body.Close()
 
+	// 오류는 응답 코드와 함께 기록하고 보내기
 	defer func() {
 		if e != nil {
-			status = http.StatusInternalServerError
-			log.Error(prefix, e)
+			record.Error = e.Error()
+			http.Error(w, http.StatusText(status), status)
 		}
-
-		log.Infof("%s : %d", prefix, status)
-
-		w.WriteHeader(status)
-		r.Body.Close()
 	}()
 
 	if r.URL.Path != "/api.json/" {
-		status = http.StatusNotFound
+		http.NotFound(w, r)
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		status = http.StatusBadRequest
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 받은 데이터를 기존 API 서버로 포워딩한 뒤 데이터 불러오기
-	message := <-nico.Fetch(r.Body)
+	fetchStart := time.Now()
+	message := <-nico.Fetch(ctx, r.Body)
+	record.UpstreamMS = milliseconds(time.Since(fetchStart))
+	metricUpstreamSeconds.Observe(time.Since(fetchStart).Seconds())
+
 	if message.Error != nil {
-		e = message.Error
+		status, e = http.StatusBadGateway, message.Error
 		return
 	}
 
-	queries := make([]string, len(message.Chats))
+	settings := currentSettings()
+	target := settings.targetLanguage(r)
+
+	record.Thread = message.Thread()
+	record.Comments = len(message.Chats)
+	record.Backend = settings.Platform
+	record.Language = target
+
+	// 캐시에 없는 코멘트만 번역하기
+	keys := make(map[int]translator.CacheKey, len(message.Chats))
+	queries := make([]string, 0, len(message.Chats))
 	for index, chat := range message.Chats {
-		queries[index] = fmt.Sprintf("§%d\n%s\n", index, chat.Content)
+		if settings.filtered(chat.Content) {
+			metricComments.Inc("filtered")
+			continue
+		}
+
+		key := translator.CacheKey{
+			Platform: settings.Platform,
+			Source:   settings.Source,
+			Target:   target,
+			Text:     settings.replaceGlossary(chat.Content),
+		}
+
+		if translated, ok := cache.Get(key); ok {
+			message.Chats[index].Content = translated
+			record.Cached++
+			metricComments.Inc("cached")
+			continue
+		}
+
+		keys[index] = key
+		queries = append(queries, fmt.Sprintf("§%d\n%s\n", index, key.Text))
 	}
 
-	log.Infof("%s : 코멘트 %d개", prefix, len(message.Chats))
+	log.Debug("코멘트를 번역합니다", fields.with("thread", record.Thread, "comments", len(message.Chats), "cached", record.Cached, "source", settings.Source, "language", target))
 
 	// 번역하기
-	translated := <-translator.Translate(queries, *langPlatform, *langSource, *langTarget)
-	if translated.Error != nil {
-		e = translated.Error
-		return
-	}
+	if len(queries) > 0 {
+		translateStart := time.Now()
+		translated := <-translator.Translate(ctx, queries, settings.Platform, settings.Source, target)
+		record.TranslateMS = milliseconds(time.Since(translateStart))
+
+		// 번역하지 못한 청크가 있어도 나머지 코멘트는 응답하기
+		translateError := translated.Error
+		for _, seq := range translated.Sequences {
+			if seq.Error != nil && translateError == nil {
+				translateError = seq.Error
+				log.Error("번역하지 못한 코멘트가 있습니다", fields.with("backend", settings.Platform, "error", seq.Error))
+				recordError(seq.Error.Error())
+			}
+		}
 
-	var translatedBytes bytes.Buffer
-	for _, seq := range translated.Sequences {
-		translatedBytes.WriteString(seq.Translated)
-	}
+		recordTranslation(settings.Platform, time.Since(translateStart), translateError)
+
+		metricTranslateSeconds.Observe(time.Since(translateStart).Seconds(), settings.Platform)
+		metricChunks.Add(float64(translated.Chunks), settings.Platform)
+		metricCharacters.Add(float64(translated.Characters), settings.Platform)
+
+		if translateError != nil {
+			metricTranslateErrors.Inc(settings.Platform)
+		}
+
+		if translated.Error != nil {
+			status, e = http.StatusInternalServerError, translated.Error
+			return
+		}
+
+		var translatedBytes bytes.Buffer
+		for _, seq := range translated.Sequences {
+			translatedBytes.WriteString(seq.Translated)
+		}
+
+		for _, groups := range queriesPattern.FindAllStringSubmatch(translatedBytes.String(), -1) {
+			index, _ := strconv.Atoi(groups[1])
+
+			// 번역기가 번호를 잘못 돌려준 경우
+			key, ok := keys[index]
+			if !ok {
+				continue
+			}
+
+			// fmt.Printf("<<< {%d} %s\n", index, message.Chats[index].Content)
+			// fmt.Printf(">>> {%d} %s\n", index, groups[2])
+			message.Chats[index].Content = groups[2]
+			cache.Add(key, groups[2])
+			record.Translated++
+		}
+
+		// 번역하지 못했거나 번역 결과에서 번호를 찾지 못한 코멘트는 원문 그대로 응답하기
+		failed := len(keys) - record.Translated
+		if failed > 0 && translateError == nil {
+			log.Warning("번역 결과에서 찾지 못한 코멘트가 있습니다", fields.with("backend", settings.Platform, "failed", failed))
+			metricAlignmentFailures.Add(float64(failed))
+		}
 
-	for _, groups := range queriesPattern.FindAllStringSubmatch(translatedBytes.String(), -1) {
-		index, _ := strconv.Atoi(groups[1])
-		// fmt.Printf("<<< {%d} %s\n", index, message.Chats[index].Content)
-		// fmt.Printf(">>> {%d} %s\n", index, groups[2])
-		message.Chats[index].Content = groups[2]
+		metricComments.Add(float64(record.Translated), "translated")
+		metricComments.Add(float64(failed), "failed")
 	}
 
 	// 변환한 메세지를 다시 페이로드로 바꾸기
+	_, encodeSpan := trace.Start(ctx, "nico.MessageToPayload")
 	payload, e := nico.MessageToPayload(message)
+	encodeSpan.SetError(e)
+	encodeSpan.Finish()
+
+	record.EncodeMS = milliseconds(encodeSpan.Duration())
+
 	if e != nil {
+		status = http.StatusInternalServerError
 		return
 	}
 
+	w.Header().Set("Content-Type", jsonContentType+"; charset=utf-8")
+	w.WriteHeader(status)
 	w.Write(payload)
 }
 
+// newHandler 코멘트 API 와 언어 설정 페이지를 처리하는 핸들러를 만듭니다
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(settingsPath, handleSettings)
+	mux.Handle("/api.json/", chain(http.HandlerFunc(handle), withAccept(jsonContentType)))
+	mux.HandleFunc("/", handle)
+
+	return chain(mux, withLogging, withRecovery, withCORS, withCompression)
+}
+
 func main() {
 	flag.Parse()
 
-	// 기록 초기화
-	backend := logging.NewLogBackend(os.Stdout, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, logFormat)
-	backendLeveled := logging.AddModuleLevel(backend)
-	backendLeveled.SetLevel(logging.ERROR, "nicotrans")
-	logging.SetBackend(backendLeveled, backendFormatter)
+	// 설정 파일 불러오기
+	if e := initConfig(); e != nil {
+		fmt.Println(e)
+		os.Exit(1)
+	}
 
-	// 호스트 파일 초기화
-	if e := initHosts(); e != nil {
-		msg := []string{
-			"호스트 파일을 수동으로 편집하고 싶다면 다음 과정을 따라해주세요",
-			"\t1) 메모장 같은 편집기를 관리자 권한으로 엽니다",
-			"\t2) %WINDIR%/System32/drivers/etc/hosts 파일을 엽니다",
-			"\t3) 가장 아래에 다음 줄을 추가하고 저장합니다",
-			"\t\t" + *serverIP + " nmsg.nicovideo.jp",
+	// systemd 나 부모 프로세스에게 물려받은 소켓
+	inherited = system.InheritedFiles()
+	privilegesDropped = inheritedFile(socketCA) != nil
+	socketActivated = len(inherited) > 0 && !privilegesDropped
+
+	// 기록 초기화, 설정 파일의 기록 설정도 사용하도록 설정 파일을 불러온 뒤 초기화하기
+	if e := initLogging(); e != nil {
+		fmt.Println(e)
+		os.Exit(1)
+	}
+
+	// 설치한 항목 제거하기
+	if flag.Arg(0) == "uninstall" {
+		if e := runUninstall(flag.Args()[1:]); e != nil {
+			fmt.Println(e)
+			os.Exit(1)
 		}
 
-		log.Errorf(e.Error())
-		log.Info(strings.Join(msg, "\n"))
+		return
+	}
+
+	// 종료할 때와 비정상 종료 후 다시 실행할 때 호스트 파일 정리하기
+	handleSignals()
+
+	// 서버를 여는 중 오류가 발생해도 등록한 종료 작업 실행하기
+	defer shutdown()
+
+	// 권한을 내린 자식 프로세스라면 부모 프로세스가 이미 초기화했음
+	// 소켓 활성화로 실행했다면 서비스 관리자가 설정을 맡으므로 검사만 하기
+	if socketActivated {
+		checkSystem()
+	} else if !privilegesDropped {
+		initSystem()
+
+		onShutdown("호스트 파일 정리", func(context.Context) error {
+			cleanupHosts()
+			return nil
+		})
+	}
+
+	// DNS 리졸버 초기화
+	if e := initResolver(); e != nil {
+		log.Panic(e)
 	}
 
 	// 인증서 초기화
-	cert, priv, e := initCertificate()
+	var cert *x509.Certificate
+	var priv interface{}
+	var e error
+	if privilegesDropped {
+		cert, priv, e = inheritedCertificate()
+	} else {
+		cert, priv, e = initCertificate()
+	}
+
+	if e != nil {
+		log.Panic(e)
+	}
+
+	// 서버 소켓 열기
+	sockets, e := openSockets()
 	if e != nil {
 		log.Panic(e)
 	}
 
+	// 관리 API 토큰 불러오기, 권한을 내린 자식 프로세스는 환경 변수로 물려받음
+	if *adminListen != "" && !privilegesDropped {
+		if e := initAdminToken(); e != nil {
+			log.Panic(e)
+		}
+	}
+
+	// 소켓을 모두 열었다면 권한 내리기
+	if supervisor() {
+		if e := dropPrivileges(sockets, cert, priv); e != nil {
+			log.Panic(e)
+		}
+	}
+
+	// 요청을 처리하는 프로세스만 지표와 트레이스 초기화하기
+	// 권한을 내렸다면 부모 프로세스는 위에서 자식 프로세스가 끝날 때까지 기다린 뒤 종료함
+	cache = translator.NewCache(*cacheSize)
+	initMetrics()
+	initTracing()
+
+	// 권한을 내린 자식 프로세스나 권한을 내리지 않은 프로세스만 번역 캐시를 저장하기
+	initCachePersistence()
+
+	// 내장 DNS 서버 실행
+	if e := initDNSServer(sockets.dnsUDP, sockets.dnsTCP); e != nil {
+		log.Panic(e)
+	}
+
+	// 접속한 호스트의 인증서를 루트 인증서로 발급하기
+	issuer, e := certificate.NewIssuer(cert, priv)
+	if e != nil {
+		log.Panic(e)
+	}
+
+	issuer.Allow = nico.IsHost
+	issuer.DefaultHost = nico.Hosts[0]
+
+	// 루트 인증서 만료 감시하기
+	go watchCertificate(issuer)
+
+	// 설정 파일이 바뀌면 다시 불러오기
+	go watchConfig()
+
+	// 인증서 설치 안내 페이지 실행
+	initOnboarding(issuer, sockets.onboard)
+
+	// 대시보드와 관리 API 실행
+	initAdmin(issuer, sockets.admin)
+
+	// 상태 확인 실행
+	initHealth(issuer, sockets.health)
+
+	tlsConfig := &tls.Config{
+		GetCertificate: issuer.GetCertificate,
+	}
+
+	// 프록시 모드라면 니코니코 코멘트 서버만 가로채는 HTTP 프록시 실행하기
+	if *proxyPort > 0 {
+		addr := serverAddr()
+
+		log.Infof("니코트랜스를 HTTP 프록시 모드로 실행합니다: %s", addr)
+		log.Infof("프록시 자동 설정 주소: http://%s/proxy.pac", addr)
+
+		p := proxy.New(nico.IsHost, tlsConfig, newHandler())
+		p.Fallback = proxy.PACHandler(nico.Hosts)
+
+		clients, e := proxyClients()
+		if e != nil {
+			log.Panic(e)
+		}
+
+		p.Clients = clients
+
+		server := &http.Server{Handler: p}
+
+		// 프록시 서버를 먼저 닫은 뒤 가로챈 연결의 요청이 끝나기를 기다리기
+		onShutdown("가로챈 연결 정리", p.Shutdown)
+		onShutdownServer("프록시 서버 종료", server)
+
+		if e := server.Serve(sockets.server); e != http.ErrServerClosed {
+			log.Panic("프록시 서버를 여는 중 오류가 발생했습니다\n", e)
+		}
+
+		shutdown()
+
+		return
+	}
+
 	// 서버 만들기
-	addr := fmt.Sprintf("%s:%d", *serverIP, *serverPort)
-	server := http.Server{
-		Addr: addr,
-		TLSConfig: &tls.Config{
-			Certificates: []tls.Certificate{
-				{
-					Certificate: [][]byte{cert.Raw},
-					PrivateKey:  priv,
-				},
-			},
-		},
-		Handler: http.HandlerFunc(handle),
+	addr := serverAddr()
+	server := &http.Server{
+		Addr:      addr,
+		TLSConfig: tlsConfig,
+		Handler:   newHandler(),
 	}
 
+	onShutdownServer("서버 종료", server)
+
 	log.Infof("니코트랜스를 실행합니다: %s", addr)
 
-	if e := server.ListenAndServeTLS("", ""); e != nil {
+	if e := server.ServeTLS(sockets.server, "", ""); e != http.ErrServerClosed {
 		log.Panic("서버를 여는 중 오류가 발생했습니다\n", e)
 	}
+
+	// 신호를 받은 고루틴이 종료 작업을 끝낼 때까지 기다리기
+	shutdown()
+}
+
+// initSystem 관리자 권한이 필요한 초기화를 합니다
+func initSystem() {
+	// 설치한 항목 기록 불러오기
+	var e error
+	if state, e = loadState(*statePath); e != nil {
+		log.Panic("상태 파일을 불러올 수 없습니다\n", e)
+	}
+
+	// 서버 포트를 열 수 있는지 확인하기
+	port := *serverPort
+	if *proxyPort > 0 {
+		port = *proxyPort
+	}
+
+	if e := initPrivileges(port); e != nil {
+		log.Panic(e)
+	}
+
+	repairHosts()
+
+	// 호스트 파일 초기화 (프록시 모드에서는 필요 없음)
+	if *proxyPort == 0 {
+		if e := initHosts(); e != nil {
+			msg := []string{
+				"호스트 파일을 수동으로 편집하고 싶다면 다음 과정을 따라해주세요",
+				"\t1) 메모장 같은 편집기를 관리자 권한으로 엽니다",
+				"\t2) " + *hostsPath + " 파일을 엽니다",
+				"\t3) 가장 아래에 다음 줄을 추가하고 저장합니다",
+			}
+			for _, host := range nico.Hosts {
+				msg = append(msg, "\t\t"+*serverIP+" "+host)
+			}
+
+			log.Errorf(e.Error())
+			log.Info(strings.Join(msg, "\n"))
+		}
+	}
+}
+
+// checkSystem 소켓 활성화로 실행했다면 권한을 얻거나 호스트 파일을 수정하지 않고 설정만 검사합니다
+func checkSystem() {
+	var e error
+	if state, e = loadState(*statePath); e != nil {
+		log.Panic("상태 파일을 불러올 수 없습니다\n", e)
+	}
+
+	if *proxyPort > 0 || !*hostsEdit {
+		return
+	}
+
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		log.Errorf("호스트 파일을 열 수 없습니다: %s", e)
+		return
+	}
+
+	for _, host := range nico.Hosts {
+		if !file.Has(*serverIP, host) {
+			log.Warningf("호스트 파일에 %s %s 항목이 없습니다, 소켓 활성화로 실행할 때는 직접 추가해야 합니다", *serverIP, host)
+		}
+	}
 }
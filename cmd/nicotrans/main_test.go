@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+)
+
+func TestCertificateTemplate(t *testing.T) {
+	priv, e := certificate.GenerateKey(certificate.ECDSA, 256)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, priv, e := certificate.CreateWithKey(newCertificateTemplate(), priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if problems := certificate.Validate(cert, priv, nico.Domains); len(problems) > 0 {
+		t.Fatal(problems)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"nmsg.nicovideo.jp", true},
+		// 가로챌 호스트가 늘어나도 루트 인증서를 다시 만들지 않아야 함
+		{"public.nvcomment.nicovideo.jp", true},
+		{"nicovideo.jp", true},
+		{"example.com", false},
+		{"nicovideo.jp.example.com", false},
+		{"evilnicovideo.jp", false},
+	}
+
+	for _, test := range tests {
+		if ok := len(certificate.Validate(cert, priv, []string{test.host})) == 0; ok != test.want {
+			t.Errorf("%s: 발급 가능 %v, 기대값 %v", test.host, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/hype5/nicotrans-go/pkg/metrics"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+)
+
+// 프로메테우스 지표
+var (
+	metricRequests = metrics.Default.NewCounterVec("nicotrans_requests_total",
+		"응답 코드별 코멘트 API 요청 수", "status")
+	metricUpstreamSeconds = metrics.Default.NewHistogramVec("nicotrans_upstream_fetch_seconds",
+		"니코니코 코멘트 서버에서 코멘트를 불러오는 시간", nil)
+	metricTranslateSeconds = metrics.Default.NewHistogramVec("nicotrans_translation_seconds",
+		"번역기별 번역 시간", nil, "platform")
+	metricTranslateErrors = metrics.Default.NewCounterVec("nicotrans_translation_errors_total",
+		"번역기별 번역 실패 수", "platform")
+	metricChunks = metrics.Default.NewCounterVec("nicotrans_translation_chunks_total",
+		"번역기로 보낸 청크 수", "platform")
+	metricCharacters = metrics.Default.NewCounterVec("nicotrans_translation_characters_total",
+		"번역기로 보낸 글자 수", "platform")
+	metricComments = metrics.Default.NewCounterVec("nicotrans_comments_total",
+		"처리한 코멘트 수 (translated, cached, filtered, failed)", "result")
+	metricAlignmentFailures = metrics.Default.NewCounterVec("nicotrans_alignment_failures_total",
+		"번역 결과에서 찾지 못한 코멘트 수")
+)
+
+// initMetrics 다른 패키지가 세고 있는 값을 지표로 등록합니다
+func initMetrics() {
+	metrics.Default.NewGaugeFunc("nicotrans_requests_in_flight", "처리 중인 코멘트 API 요청 수", func() float64 {
+		return float64(activeRequests())
+	})
+
+	metrics.Default.NewCounterFunc("nicotrans_cache_hits_total", "번역 캐시에서 찾은 코멘트 수", func() float64 {
+		return float64(cache.Stats().Hits)
+	})
+
+	metrics.Default.NewCounterFunc("nicotrans_cache_misses_total", "번역 캐시에 없던 코멘트 수", func() float64 {
+		return float64(cache.Stats().Misses)
+	})
+
+	metrics.Default.NewGaugeFunc("nicotrans_cache_entries", "번역 캐시에 기억한 코멘트 수", func() float64 {
+		return float64(cache.Stats().Len)
+	})
+
+	metrics.Default.NewCounterFunc("nicotrans_dns_cache_refreshes_total", "니코니코 서버 주소를 다시 조회한 횟수", func() float64 {
+		return float64(nico.Resolver.Cache.Stats().Refreshes)
+	})
+
+	metrics.Default.NewCounterFunc("nicotrans_dns_cache_errors_total", "니코니코 서버 주소 조회에 실패한 횟수", func() float64 {
+		return float64(nico.Resolver.Cache.Stats().Errors)
+	})
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/trace"
+)
+
+// middleware 핸들러를 감싸 요청 전후에 공통 작업을 합니다
+type middleware func(http.Handler) http.Handler
+
+// chain 핸들러를 미들웨어로 감쌉니다, 첫 번째 미들웨어가 가장 바깥에서 실행됩니다
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// responseRecorder 응답 코드와 크기를 기록합니다
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int
+	wroteHeader bool
+
+	// 헤더를 보내기 직전에 부를 함수
+	beforeHeader func()
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+
+	rec.wroteHeader = true
+	rec.status = status
+
+	if rec.beforeHeader != nil {
+		rec.beforeHeader()
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	n, e := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+
+	return n, e
+}
+
+// Flush 감싼 ResponseWriter 가 지원한다면 버퍼에 있는 응답을 보냅니다
+func (rec *responseRecorder) Flush() {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type requestRecordKey struct{}
+
+// requestRecordFrom 로깅 미들웨어가 만든 요청 기록을 반환합니다, 핸들러가 채운 값은 요청이 끝나면 기록됩니다
+func requestRecordFrom(ctx context.Context) *requestRecord {
+	if record, ok := ctx.Value(requestRecordKey{}).(*requestRecord); ok {
+		return record
+	}
+
+	return &requestRecord{Time: time.Now()}
+}
+
+// newRequestID 요청 아이디를 만듭니다
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// withLogging 요청마다 트레이스와 요청 기록을 만들고 요청이 끝나면 기록, 통계, 지표에 남깁니다
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 요청을 보낸 쪽의 트레이스가 있다면 이어서 기록하기
+		ctx, span := trace.Start(trace.Extract(r.Context(), r.Header.Get("traceparent")), "nicotrans.handle")
+		span.Kind = trace.KindServer
+
+		// 요청 아이디는 클라이언트가 정할 수 없도록 서버에서 만들고 트레이스 아이디는 따로 기록하기
+		record := &requestRecord{Time: span.Start, RequestID: newRequestID(), TraceID: span.TraceID.String(), Client: r.RemoteAddr, Path: r.URL.Path}
+		finish := beginRequest()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		rec.beforeHeader = func() {
+			record.DurationMS = milliseconds(time.Since(record.Time))
+			setServerTiming(w, *record)
+		}
+
+		w.Header().Set("X-Request-ID", record.RequestID)
+
+		defer func() {
+			// 아무것도 쓰지 않은 핸들러는 200 으로 응답됨
+			record.Status = rec.status
+			if !rec.wroteHeader {
+				record.Status = http.StatusOK
+			}
+
+			record.DurationMS = milliseconds(time.Since(record.Time))
+			finish(*record)
+
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.Path)
+			span.SetAttribute("http.status_code", record.Status)
+			span.SetAttribute("http.response_content_length", rec.bytes)
+			span.SetAttribute("nico.thread", record.Thread)
+			span.SetAttribute("nico.comments", record.Comments)
+			span.SetAttribute("nicotrans.cached", record.Cached)
+			span.SetAttribute("nicotrans.translated", record.Translated)
+			span.SetAttribute("nicotrans.language", record.Language)
+			if record.Error != "" {
+				span.Error = record.Error
+			}
+			span.Finish()
+
+			logRequest(r, *record, rec.bytes)
+
+			metricRequests.Inc(strconv.Itoa(record.Status))
+		}()
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(ctx, requestRecordKey{}, record)))
+	})
+}
+
+// logRequest 처리한 요청을 기록합니다, 실패한 요청은 오류로 기록합니다
+func logRequest(r *http.Request, record requestRecord, bytes int) {
+	fields := requestFields(record).with("method", r.Method, "bytes", bytes, "referer", r.Referer())
+
+	if record.Error != "" || record.Status >= http.StatusInternalServerError {
+		log.Error("요청을 처리하지 못했습니다", fields.with("error", record.Error))
+	} else {
+		log.Info("요청을 처리했습니다", fields)
+	}
+}
+
+// withRecovery 핸들러에서 패닉이 발생해도 서버가 멈추지 않도록 오류를 기록하고 500 으로 응답합니다
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			// 연결을 끊으려고 일부러 발생시킨 패닉
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			record := requestRecordFrom(r.Context())
+			record.Error = fmt.Sprintf("요청을 처리하는 중 패닉이 발생했습니다: %v", v)
+
+			log.Errorf("%s\n%s", record.Error, debug.Stack())
+
+			// 이미 응답을 보내기 시작했다면 연결을 끊을 수밖에 없음
+			if rec.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+
+			http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// withCORS 니코니코 플레이어가 다른 사이트에서 요청할 수 있도록 CORS 헤더를 붙이고 OPTIONS 요청에 응답합니다
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", "*")
+		header.Set("Access-Control-Expose-Headers", "X-Request-ID, Server-Timing")
+		header.Set("Timing-Allow-Origin", "*")
+
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Set("Allow", "GET, POST, OPTIONS")
+
+		// 사전 요청이라면 허용하는 방식과 헤더 알려주기
+		if r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			header.Set("Access-Control-Max-Age", "86400")
+
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// withAccept Accept 헤더가 offers 를 하나도 받지 않는 요청에 406 으로 응답합니다
+func withAccept(offers ...string) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if accept := r.Header.Get("Accept"); accept != "" && negotiate(accept, offers...) == "" {
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 압축할 수 있는 방식, 앞에 있는 방식을 먼저 사용합니다
+var compressEncodings = []string{"gzip", "deflate"}
+
+// withCompression Accept-Encoding 에 따라 응답을 gzip 이나 deflate 로 압축합니다
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiate(r.Header.Get("Accept-Encoding"), compressEncodings...)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter 본문이 있는 응답만 압축합니다
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding    string
+	writer      compressor
+	wroteHeader bool
+}
+
+// compressor gzip.Writer 와 zlib.Writer 가 함께 구현하는 메서드
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		switch cw.encoding {
+		case "gzip":
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		case "deflate":
+			// HTTP 의 deflate 는 zlib 형식
+			cw.writer = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		// 압축한 본문으로는 형식을 알 수 없으니 압축하기 전에 알아내기
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	return cw.writer.Write(b)
+}
+
+// Flush 지금까지 압축한 데이터를 보냅니다, 응답을 나눠 보낼 때 클라이언트가 기다리지 않도록 합니다
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.writer != nil {
+		if e := cw.writer.Flush(); e != nil {
+			return
+		}
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close 압축한 데이터를 모두 보냅니다
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+
+	return cw.writer.Close()
+}
+
+// negotiate Accept 형식의 헤더에서 offers 중 가장 선호하는 값을 고릅니다
+// 헤더가 비어있거나 받을 수 있는 값이 없다면 빈 문자열을 반환합니다
+func negotiate(header string, offers ...string) string {
+	best := ""
+	bestQ := 0.0
+
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+
+		for _, part := range strings.Split(header, ",") {
+			value, partQ := parseAcceptValue(part)
+			if value == "" {
+				continue
+			}
+
+			// 가장 구체적으로 일치하는 값의 q 를 사용하기
+			if s := acceptSpecificity(value, offer); s > specificity {
+				q, specificity = partQ, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// parseAcceptValue "gzip;q=0.5" 를 값과 q 로 나눕니다
+func parseAcceptValue(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	value := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(strings.ToLower(param), "q=") {
+			continue
+		}
+
+		if v, e := strconv.ParseFloat(param[2:], 64); e == nil {
+			q = v
+		}
+	}
+
+	return value, q
+}
+
+// acceptSpecificity 값이 offer 와 일치한다면 얼마나 구체적인지 반환하고 일치하지 않는다면 -1 을 반환합니다
+func acceptSpecificity(value, offer string) int {
+	offer = strings.ToLower(offer)
+
+	switch {
+	case value == offer:
+		return 2
+	case strings.HasSuffix(value, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(value, "*")):
+		return 1
+	case value == "*" || value == "*/*":
+		return 0
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	var record requestRecord
+	handler := withLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record = *requestRecordFrom(r.Context())
+	}))
+
+	tests := []struct {
+		name        string
+		traceparent string
+		remote      bool
+	}{
+		{"트레이스 없음", "", false},
+		{"클라이언트 트레이스", "00-" + traceID + "-00f067aa0ba902b7-01", true},
+		{"잘못된 트레이스", "00-" + traceID + "-xyz-01", false},
+	}
+
+	seen := map[string]bool{}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api.json/", nil)
+		if test.traceparent != "" {
+			req.Header.Set("traceparent", test.traceparent)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		id := w.Header().Get("X-Request-ID")
+
+		// 요청 아이디는 서버가 만들어야 함
+		if id != record.RequestID || len(id) != 16 || id == traceID || seen[id] {
+			t.Errorf("%s: 요청 아이디 %q, 기록 %q", test.name, id, record.RequestID)
+		}
+
+		seen[id] = true
+
+		if got := record.TraceID == traceID; got != test.remote || len(record.TraceID) != 32 {
+			t.Errorf("%s: 트레이스 아이디 %q", test.name, record.TraceID)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	handler := newHandler()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		methods string
+	}{
+		{"OPTIONS 요청", nil, ""},
+		{"사전 요청", map[string]string{"Access-Control-Request-Method": "POST"}, "GET, POST, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodOptions, "/api.json/", nil)
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, POST, OPTIONS" {
+			t.Errorf("%s: %d, Allow %q", test.name, w.Code, w.Header().Get("Allow"))
+		}
+
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != test.methods {
+			t.Errorf("%s: Access-Control-Allow-Methods %q, 기대값 %q", test.name, got, test.methods)
+		}
+	}
+}
+
+func TestWithAccept(t *testing.T) {
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withAccept(jsonContentType))
+
+	tests := []struct {
+		accept string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"application/json", http.StatusOK},
+		{"application/*", http.StatusOK},
+		{"*/*;q=0.1", http.StatusOK},
+		{"text/html, application/json;q=0.5", http.StatusOK},
+		{"text/html", http.StatusNotAcceptable},
+		{"application/json;q=0", http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api.json/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != test.want {
+			t.Errorf("%q: %d, 기대값 %d", test.accept, w.Code, test.want)
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	tests := []struct {
+		encoding string
+		reader   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	}
+
+	for _, test := range tests {
+		var flushed []byte
+		recorder := httptest.NewRecorder()
+
+		handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", jsonContentType)
+			io.WriteString(w, `{"chat":`)
+
+			// 응답이 끝나기 전에 보낸 데이터만으로 압축을 풀 수 있어야 함
+			w.(http.Flusher).Flush()
+			flushed = append([]byte(nil), recorder.Body.Bytes()...)
+
+			io.WriteString(w, `1}`)
+		}), withLogging, withCompression)
+
+		req := httptest.NewRequest(http.MethodPost, "/api.json/", nil)
+		req.Header.Set("Accept-Encoding", test.encoding)
+
+		handler.ServeHTTP(recorder, req)
+
+		if !recorder.Flushed || recorder.Header().Get("Content-Encoding") != test.encoding {
+			t.Errorf("%s: 보냄 %v, Content-Encoding %q", test.encoding, recorder.Flushed, recorder.Header().Get("Content-Encoding"))
+		}
+
+		r, e := test.reader(bytes.NewReader(flushed))
+		if e != nil {
+			t.Fatalf("%s: %v", test.encoding, e)
+		}
+
+		// 압축이 끝나지 않았으니 EOF 대신 받은 데이터까지만 읽힘
+		partial := make([]byte, 64)
+		n, _ := io.ReadFull(r, partial)
+		if got := string(partial[:n]); got != `{"chat":` {
+			t.Errorf("%s: 보낸 데이터 %q", test.encoding, got)
+		}
+
+		r, e = test.reader(recorder.Body)
+		if e != nil {
+			t.Fatalf("%s: %v", test.encoding, e)
+		}
+
+		if body, e := ioutil.ReadAll(r); e != nil || string(body) != `{"chat":1}` {
+			t.Errorf("%s: %q %v", test.encoding, body, e)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/system"
+)
+
+// 물려받은 소켓 이름
+const (
+	socketServer  = "server"
+	socketOnboard = "onboard"
+	socketAdmin   = "admin"
+	socketHealth  = "health"
+	socketDNSUDP  = "dns-udp"
+	socketDNSTCP  = "dns-tcp"
+	socketCA      = "ca"
+)
+
+// systemd 나 부모 프로세스에게 물려받은 소켓
+var inherited []system.InheritedFile
+
+// 권한을 내린 자식 프로세스로 실행 중인지?
+var privilegesDropped bool
+
+// systemd 소켓 활성화로 실행 중인지?
+// 서비스 관리자가 소켓, 사용자, 호스트 파일과 인증서 설치를 맡으므로 관리자 권한이 필요한 설정은 하지 않습니다
+var socketActivated bool
+
+// supervisor 권한을 내린 자식 프로세스를 실행하고 감시하기만 하는 부모 프로세스인지?
+// 요청은 자식 프로세스가 처리하므로 부모 프로세스는 기록 파일이나 번역 캐시를 쓰지 않습니다
+func supervisor() bool {
+	return *runUser != "" && !privilegesDropped && flag.Arg(0) != "uninstall"
+}
+
+// 권한을 내려 실행한 자식 프로세스
+var child struct {
+	mu      sync.Mutex
+	process *os.Process
+}
+
+// serverSockets 서버가 사용할 소켓
+type serverSockets struct {
+	server  net.Listener
+	onboard net.Listener
+	admin   net.Listener
+	health  net.Listener
+	dnsUDP  net.PacketConn
+	dnsTCP  net.Listener
+}
+
+// inheritedFile 이름으로 물려받은 소켓을 찾습니다
+// 이름 없이 하나만 물려받았다면 서버 소켓으로 사용합니다
+func inheritedFile(name string) *os.File {
+	for _, f := range inherited {
+		if f.Name == name {
+			return f.File
+		}
+	}
+
+	if name == socketServer && len(inherited) == 1 && inherited[0].Name == "unknown" {
+		return inherited[0].File
+	}
+
+	return nil
+}
+
+// listen 물려받은 소켓이 있다면 사용하고 없다면 새로 엽니다
+func listen(name string, addr string) (net.Listener, error) {
+	if f := inheritedFile(name); f != nil {
+		defer f.Close()
+
+		log.Infof("물려받은 %s 소켓을 사용합니다", name)
+
+		return net.FileListener(f)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+func listenPacket(name string, addr string) (net.PacketConn, error) {
+	if f := inheritedFile(name); f != nil {
+		defer f.Close()
+
+		log.Infof("물려받은 %s 소켓을 사용합니다", name)
+
+		return net.FilePacketConn(f)
+	}
+
+	return net.ListenPacket("udp", addr)
+}
+
+// openSockets 서버가 사용할 소켓을 모두 엽니다
+// 권한을 내리기 전에 열어야 1024 미만 포트를 사용할 수 있습니다
+func openSockets() (*serverSockets, error) {
+	sockets := &serverSockets{}
+
+	var e error
+	if sockets.server, e = listen(socketServer, serverAddr()); e != nil {
+		return nil, fmt.Errorf("서버를 여는 중 오류가 발생했습니다: %s", e)
+	}
+
+	if *onboardListen != "" {
+		if sockets.onboard, e = listen(socketOnboard, *onboardListen); e != nil {
+			return nil, fmt.Errorf("인증서 설치 안내 페이지를 여는 중 오류가 발생했습니다: %s", e)
+		}
+	}
+
+	if *adminListen != "" {
+		if sockets.admin, e = listen(socketAdmin, *adminListen); e != nil {
+			return nil, fmt.Errorf("대시보드를 여는 중 오류가 발생했습니다: %s", e)
+		}
+	}
+
+	if *healthListen != "" {
+		if sockets.health, e = listen(socketHealth, *healthListen); e != nil {
+			return nil, fmt.Errorf("상태 확인을 여는 중 오류가 발생했습니다: %s", e)
+		}
+	}
+
+	if *dnsListen != "" {
+		if sockets.dnsUDP, e = listenPacket(socketDNSUDP, *dnsListen); e != nil {
+			return nil, fmt.Errorf("DNS 서버를 여는 중 오류가 발생했습니다: %s", e)
+		}
+
+		if sockets.dnsTCP, e = listen(socketDNSTCP, *dnsListen); e != nil {
+			return nil, fmt.Errorf("DNS 서버를 여는 중 오류가 발생했습니다: %s", e)
+		}
+	}
+
+	return sockets, nil
+}
+
+// serverAddr 서버 주소를 반환합니다, 프록시 모드라면 프록시 포트를 사용합니다
+func serverAddr() string {
+	if *proxyPort > 0 {
+		return fmt.Sprintf("%s:%d", *serverIP, *proxyPort)
+	}
+
+	return fmt.Sprintf("%s:%d", *serverIP, *serverPort)
+}
+
+// filer 소켓의 파일 디스크립터를 복사할 수 있는 타입
+type filer interface {
+	File() (*os.File, error)
+}
+
+// dropPrivileges 열어둔 소켓과 루트 인증서를 넘겨주고 지정한 사용자로 자식 프로세스를 실행합니다
+// 현재 프로세스는 관리자 권한을 유지한 채 자식 프로세스가 종료될 때까지 기다린 뒤
+// 호스트 파일 정리 같은 종료 작업을 실행하고 종료합니다
+//
+// 루트 인증서의 키는 파일이 아니라 파이프로 넘겨주므로 키 파일은 관리자만 읽을 수 있게 둘 수 있지만
+// 자식 프로세스의 메모리에는 키가 있기 때문에 자식 프로세스를 장악하면 키도 유출됩니다
+// 이름 제약 조건 때문에 유출된 키로는 니코니코 도메인의 인증서만 만들 수 있습니다
+//
+// 자식 프로세스가 쓰는 기록 파일과 번역 캐시는 prepareChildFiles 로 실행하기 전에 확인합니다
+func dropPrivileges(sockets *serverSockets, cert *x509.Certificate, priv interface{}) error {
+	if e := prepareChildFiles(); e != nil {
+		return e
+	}
+
+	var files []system.InheritedFile
+
+	add := func(name string, socket interface{}) error {
+		f, e := socket.(filer).File()
+		if e != nil {
+			return e
+		}
+
+		files = append(files, system.InheritedFile{Name: name, File: f})
+
+		return nil
+	}
+
+	if e := add(socketServer, sockets.server); e != nil {
+		return e
+	}
+
+	if sockets.onboard != nil {
+		if e := add(socketOnboard, sockets.onboard); e != nil {
+			return e
+		}
+	}
+
+	if sockets.admin != nil {
+		if e := add(socketAdmin, sockets.admin); e != nil {
+			return e
+		}
+	}
+
+	if sockets.health != nil {
+		if e := add(socketHealth, sockets.health); e != nil {
+			return e
+		}
+	}
+
+	if sockets.dnsUDP != nil {
+		if e := add(socketDNSUDP, sockets.dnsUDP); e != nil {
+			return e
+		}
+
+		if e := add(socketDNSTCP, sockets.dnsTCP); e != nil {
+			return e
+		}
+	}
+
+	// 키 파일을 읽을 권한이 없어도 되도록 루트 인증서는 파이프로 전달하기
+	r, w, e := os.Pipe()
+	if e != nil {
+		return e
+	}
+
+	files = append(files, system.InheritedFile{Name: socketCA, File: r})
+
+	log.Infof("%s 사용자로 권한을 내려 실행합니다", *runUser)
+
+	child.mu.Lock()
+	child.process, e = system.StartAs(*runUser, *runGroup, files)
+	child.mu.Unlock()
+
+	for _, f := range files {
+		f.File.Close()
+	}
+
+	if e != nil {
+		w.Close()
+		return fmt.Errorf("권한을 내려 실행할 수 없습니다: %s", e)
+	}
+
+	privBlock, e := certificate.MarshalPrivateKey(priv, true, nil)
+	if e != nil {
+		w.Close()
+		return e
+	}
+
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	pem.Encode(w, privBlock)
+	w.Close()
+
+	// 부모 프로세스는 더 이상 소켓을 사용하지 않음
+	sockets.close()
+
+	status, e := child.process.Wait()
+	if e != nil {
+		return e
+	}
+
+	shutdown()
+	os.Exit(status.ExitCode())
+
+	return nil
+}
+
+// prepareChildFiles 권한을 내린 자식 프로세스가 쓰는 파일을 그 사용자가 쓸 수 있는지 확인하고 넘겨줍니다
+// 기록 파일과 번역 캐시는 자식 프로세스가 새 파일을 만들어 교체하기 때문에 폴더에도 파일을 만들 수 있어야 합니다
+// 상태 파일과 호스트 파일은 관리자 권한을 유지한 부모 프로세스만 쓰므로 넘겨주지 않습니다
+func prepareChildFiles() error {
+	for _, path := range []string{*logPath, *cacheFile} {
+		if path == "" {
+			continue
+		}
+
+		if e := system.CheckWritable(filepath.Dir(path), *runUser, *runGroup); e != nil {
+			return fmt.Errorf("권한을 내린 프로세스가 %s 파일을 쓸 수 없습니다: %s", path, e)
+		}
+
+		// 관리자 권한으로 실행하며 만든 파일이라면 자식 프로세스가 열 수 있도록 소유자 바꾸기
+		if e := system.ChownTo(path, *runUser, *runGroup); e != nil && !os.IsNotExist(e) {
+			return fmt.Errorf("%s 파일의 소유자를 바꿀 수 없습니다: %s", path, e)
+		}
+	}
+
+	return nil
+}
+
+// supervisedProcess 권한을 내려 실행한 자식 프로세스를 반환합니다
+func supervisedProcess() *os.Process {
+	child.mu.Lock()
+	defer child.mu.Unlock()
+
+	return child.process
+}
+
+// inheritedCertificate 부모 프로세스가 파이프로 전달한 루트 인증서를 불러옵니다
+func inheritedCertificate() (*x509.Certificate, interface{}, error) {
+	f := inheritedFile(socketCA)
+	defer f.Close()
+
+	data, e := ioutil.ReadAll(f)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	_, rest := pem.Decode(data)
+
+	return certificate.Parse(data[:len(data)-len(rest)], rest, nil)
+}
+
+func (s *serverSockets) close() {
+	for _, l := range []net.Listener{s.server, s.onboard, s.admin, s.health, s.dnsTCP} {
+		if l != nil {
+			l.Close()
+		}
+	}
+
+	if s.dnsUDP != nil {
+		s.dnsUDP.Close()
+	}
+}
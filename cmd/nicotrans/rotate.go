@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+)
+
+// 만료 감시와 관리 API 가 동시에 인증서를 교체하지 않도록 잠그기
+var rotateMu sync.Mutex
+
+// watchCertificate 주기적으로 루트 인증서 만료를 확인하고 필요하면 교체합니다, 종료할 때 멈춥니다
+func watchCertificate(issuer *certificate.Issuer) {
+	ticker := time.NewTicker(*certCheckInterval)
+	defer ticker.Stop()
+
+	stop := make(chan struct{})
+	onShutdown("인증서 만료 감시 종료", func(context.Context) error {
+		close(stop)
+		return nil
+	})
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ca := issuer.CA()
+
+		switch {
+		case certificate.ExpiresWithin(ca, *certRenewBefore):
+			if e := canRotateCertificate(); e != nil {
+				log.Errorf("인증서가 %s 에 만료되지만 새 인증서를 만들 수 없습니다: %s", ca.NotAfter.Format(time.RFC3339), e)
+				continue
+			}
+
+			log.Warningf("인증서가 %s 에 만료되어 새 인증서로 교체합니다", ca.NotAfter.Format(time.RFC3339))
+
+			if e := rotateCertificate(issuer); e != nil {
+				log.Errorf("인증서를 교체할 수 없습니다: %s", e)
+			}
+		case certificate.ExpiresWithin(ca, *certRenewBefore*2):
+			log.Warningf("인증서가 %s 에 만료됩니다", ca.NotAfter.Format(time.RFC3339))
+		}
+	}
+}
+
+// canRotateCertificate 새 루트 인증서를 만들 수 있는지 확인합니다
+func canRotateCertificate() error {
+	if !*certCreate {
+		return fmt.Errorf("cert-create 설정이 꺼져있습니다")
+	}
+
+	if privilegesDropped {
+		return fmt.Errorf("권한을 내려 실행 중이라 인증서를 저장할 수 없습니다")
+	}
+
+	return nil
+}
+
+// rotateCertificate 새 루트 인증서를 만들어 설치하고 서버를 멈추지 않고 교체합니다
+func rotateCertificate(issuer *certificate.Issuer) error {
+	rotateMu.Lock()
+	defer rotateMu.Unlock()
+
+	cert, priv, e := createCertificate()
+	if e != nil {
+		return e
+	}
+
+	exportCertificate(cert)
+
+	if *certInstall {
+		installCertificate(cert)
+	}
+
+	if e := issuer.SetCA(cert, priv); e != nil {
+		return e
+	}
+
+	log.Info("인증서를 교체했습니다")
+
+	return nil
+}
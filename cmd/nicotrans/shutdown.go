@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/hype5/nicotrans-go/pkg/fileutil"
+	"github.com/hype5/nicotrans-go/pkg/translator"
+)
+
+// shutdownHook 종료할 때 실행할 작업
+type shutdownHook struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// 종료할 때 실행할 작업, 나중에 등록한 작업부터 실행합니다
+var shutdownHooks struct {
+	mu    sync.Mutex
+	hooks []shutdownHook
+	once  sync.Once
+}
+
+// onShutdown 종료할 때 실행할 작업을 등록합니다
+// 서버처럼 나중에 시작한 작업이 먼저 멈추도록 등록한 반대 순서로 실행합니다
+func onShutdown(name string, run func(ctx context.Context) error) {
+	shutdownHooks.mu.Lock()
+	defer shutdownHooks.mu.Unlock()
+
+	shutdownHooks.hooks = append(shutdownHooks.hooks, shutdownHook{name: name, run: run})
+}
+
+// shutdown 등록한 작업을 모두 실행합니다, 작업마다 shutdown-timeout 동안 기다립니다
+// 여러 번 불러도 한 번만 실행하고 다른 고루틴은 실행이 끝날 때까지 기다립니다
+func shutdown() {
+	shutdownHooks.once.Do(func() {
+		shutdownHooks.mu.Lock()
+		hooks := append([]shutdownHook{}, shutdownHooks.hooks...)
+		shutdownHooks.mu.Unlock()
+
+		for i := len(hooks) - 1; i >= 0; i-- {
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+
+			if e := hooks[i].run(ctx); e != nil {
+				log.Errorf("%s 작업을 끝내지 못했습니다: %s", hooks[i].name, e)
+			}
+
+			cancel()
+		}
+	})
+}
+
+// onShutdownServer 종료할 때 새 연결을 받지 않고 처리 중인 요청이 끝날 때까지 기다리도록 서버를 등록합니다
+// shutdown-timeout 이 지나면 남은 연결을 닫습니다
+func onShutdownServer(name string, server *http.Server) {
+	onShutdown(name, func(ctx context.Context) error {
+		e := server.Shutdown(ctx)
+		if e != nil {
+			server.Close()
+		}
+
+		return e
+	})
+}
+
+// loadCache 이전에 저장한 번역 캐시를 불러옵니다
+func loadCache(path string) error {
+	data, e := ioutil.ReadFile(path)
+	if os.IsNotExist(e) {
+		return nil
+	} else if e != nil {
+		return e
+	}
+
+	var entries []adminCacheEntry
+	if e := json.Unmarshal(data, &entries); e != nil {
+		return e
+	}
+
+	// 최근에 사용한 순서로 저장했기 때문에 오래된 것부터 추가하기
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		cache.Add(translator.CacheKey{
+			Platform: entry.Platform,
+			Source:   entry.Source,
+			Target:   entry.Target,
+			Text:     entry.Text,
+		}, entry.Translated)
+	}
+
+	log.Infof("번역 캐시 %d개를 불러왔습니다: %s", len(entries), path)
+
+	return nil
+}
+
+// saveCache 번역 캐시를 파일로 저장합니다, 저장하다 멈춰도 이전 파일이 남도록 임시 파일에 쓴 뒤 교체합니다
+func saveCache(path string) error {
+	entries := []adminCacheEntry{}
+	for _, entry := range cache.Entries(0) {
+		entries = append(entries, adminCacheEntry{
+			Platform:   entry.Key.Platform,
+			Source:     entry.Key.Source,
+			Target:     entry.Key.Target,
+			Text:       entry.Key.Text,
+			Translated: entry.Translated,
+		})
+	}
+
+	data, e := json.Marshal(entries)
+	if e != nil {
+		return e
+	}
+
+	if e := fileutil.WriteAtomic(path, data, 0600); e != nil {
+		return e
+	}
+
+	log.Infof("번역 캐시 %d개를 저장했습니다: %s", len(entries), path)
+
+	return nil
+}
+
+// initCachePersistence 번역 캐시를 불러오고 종료할 때 저장하도록 등록합니다
+func initCachePersistence() {
+	if *cacheFile == "" {
+		return
+	}
+
+	if e := loadCache(*cacheFile); e != nil {
+		log.Errorf("번역 캐시를 불러올 수 없습니다: %s", e)
+	}
+
+	onShutdown("번역 캐시 저장", func(context.Context) error {
+		return saveCache(*cacheFile)
+	})
+}
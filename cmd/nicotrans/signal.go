@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals 종료 신호를 받으면 처리 중인 요청을 마치고 종료 작업을 실행한 뒤 종료하고, SIGHUP 을 받으면 설정을 다시 불러옵니다
+// 종료 중에 신호를 한 번 더 받으면 호스트 파일만 정리하고 바로 종료합니다
+// 권한을 내린 자식 프로세스가 있다면 신호를 전달하고 자식 프로세스가 종료된 뒤 정리합니다
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+		stopping := false
+
+		for sig := range signals {
+			if p := supervisedProcess(); p != nil {
+				p.Signal(sig)
+				continue
+			}
+
+			if sig == syscall.SIGHUP {
+				select {
+				case reloadRequests <- struct{}{}:
+				default:
+				}
+
+				continue
+			}
+
+			if stopping {
+				log.Warningf("%s 신호를 다시 받아 바로 종료합니다", sig)
+
+				cleanupHosts()
+				os.Exit(1)
+			}
+
+			stopping = true
+
+			log.Infof("%s 신호를 받아 종료합니다, 처리 중인 요청을 %s 동안 기다립니다", sig, *shutdownTimeout)
+
+			go func() {
+				shutdown()
+				os.Exit(0)
+			}()
+		}
+	}()
+}
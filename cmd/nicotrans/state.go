@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/hype5/nicotrans-go/pkg/fileutil"
+)
+
+// installedCertificate 저장소에 설치한 인증서
+type installedCertificate struct {
+	Store       string `json:"store"`
+	Certificate []byte `json:"certificate"`
+}
+
+// installedHost 호스트 파일에 추가한 항목
+type installedHost struct {
+	IP   string `json:"ip"`
+	Host string `json:"host"`
+}
+
+// installState 니코트랜스가 설치한 항목, 제거할 때 이 목록만 정확히 되돌립니다
+type installState struct {
+	Certificates []installedCertificate `json:"certificates,omitempty"`
+	Hosts        []installedHost        `json:"hosts,omitempty"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// loadState 상태 파일을 불러옵니다, 파일이 없다면 빈 상태를 반환합니다
+func loadState(path string) (*installState, error) {
+	state := &installState{path: path}
+
+	data, e := ioutil.ReadFile(path)
+	if os.IsNotExist(e) {
+		return state, nil
+	} else if e != nil {
+		return nil, e
+	}
+
+	if e := json.Unmarshal(data, state); e != nil {
+		return nil, e
+	}
+
+	return state, nil
+}
+
+// save 상태 파일을 저장합니다, 남은 항목이 없다면 파일을 지웁니다
+// 제거할 때 이 파일만 보고 되돌리기 때문에 저장하다 멈춰도 이전 파일이 남도록 임시 파일에 쓴 뒤 교체합니다
+func (s *installState) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.empty() {
+		if e := os.Remove(s.path); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+
+		return nil
+	}
+
+	data, e := json.MarshalIndent(s, "", "\t")
+	if e != nil {
+		return e
+	}
+
+	return fileutil.WriteAtomic(s.path, data, 0600)
+}
+
+func (s *installState) empty() bool {
+	return len(s.Certificates) == 0 && len(s.Hosts) == 0
+}
+
+func (s *installState) addCertificate(store string, raw []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.Certificates {
+		if c.Store == store && bytes.Equal(c.Certificate, raw) {
+			return
+		}
+	}
+
+	s.Certificates = append(s.Certificates, installedCertificate{Store: store, Certificate: raw})
+}
+
+func (s *installState) addHost(ip, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.Hosts {
+		if h.IP == ip && h.Host == host {
+			return
+		}
+	}
+
+	s.Hosts = append(s.Hosts, installedHost{IP: ip, Host: host})
+}
+
+func (s *installState) removeHost(ip, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hosts []installedHost
+	for _, h := range s.Hosts {
+		if h.IP != ip || h.Host != host {
+			hosts = append(hosts, h)
+		}
+	}
+
+	s.Hosts = hosts
+}
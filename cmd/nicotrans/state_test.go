@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nicotrans.state.json")
+
+	state, e := loadState(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *installState)
+		hosts  int
+		exists bool
+	}{
+		{"항목 추가", func(s *installState) { s.addHost("127.0.0.1", "nmsg.nicovideo.jp") }, 1, true},
+		{"인증서 추가", func(s *installState) { s.addCertificate("system", []byte{1, 2, 3}) }, 1, true},
+		{"같은 항목 다시 추가", func(s *installState) { s.addHost("127.0.0.1", "nmsg.nicovideo.jp") }, 1, true},
+		{"다른 항목 추가", func(s *installState) { s.addHost("::1", "nmsg.nicovideo.jp") }, 2, true},
+		{"항목 제거", func(s *installState) { s.removeHost("::1", "nmsg.nicovideo.jp") }, 1, true},
+		{"모두 제거", func(s *installState) {
+			s.removeHost("127.0.0.1", "nmsg.nicovideo.jp")
+			s.Certificates = nil
+		}, 0, false},
+	}
+
+	for _, test := range tests {
+		test.change(state)
+
+		if e := state.save(); e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+
+		// 저장한 내용을 다시 불러올 수 있어야 함
+		if loaded, e := loadState(path); e != nil {
+			t.Errorf("%s: %v", test.name, e)
+		} else if len(loaded.Hosts) != test.hosts {
+			t.Errorf("%s: 호스트 %d개, 기대값 %d개", test.name, len(loaded.Hosts), test.hosts)
+		}
+
+		if _, e := os.Stat(path); (e == nil) != test.exists {
+			t.Errorf("%s: 상태 파일 %v, 기대값 %v", test.name, e == nil, test.exists)
+		}
+
+		// 임시 파일이 남지 않아야 함
+		files, _ := ioutil.ReadDir(dir)
+		for _, file := range files {
+			if file.Name() != filepath.Base(path) {
+				t.Errorf("%s: 남은 파일 %s", test.name, file.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 대시보드에 보여줄 최근 요청과 오류 수
+const recentLimit = 50
+
+// 니코트랜스를 실행한 시각
+var startedAt = time.Now()
+
+// requestRecord 처리한 요청 하나의 기록
+type requestRecord struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id"`
+	TraceID     string    `json:"trace_id"`
+	Client      string    `json:"client"`
+	Path        string    `json:"path"`
+	Status      int       `json:"status"`
+	Thread      string    `json:"thread,omitempty"`
+	Comments    int       `json:"comments"`
+	Translated  int       `json:"translated"`
+	Cached      int       `json:"cached"`
+	Backend     string    `json:"backend,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	DurationMS  float64   `json:"duration_ms"`
+	UpstreamMS  float64   `json:"upstream_ms"`
+	TranslateMS float64   `json:"translate_ms"`
+	EncodeMS    float64   `json:"encode_ms"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// errorRecord 최근 오류
+type errorRecord struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// backendStatus 번역기 상태, 마지막 번역이 성공했다면 정상으로 봅니다
+type backendStatus struct {
+	Platform    string    `json:"platform"`
+	Healthy     bool      `json:"healthy"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   time.Time `json:"last_error,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// 실행 중 통계
+var stats struct {
+	mu sync.Mutex
+
+	active   int
+	total    uint64
+	requests []requestRecord
+	errors   []errorRecord
+
+	translations  uint64
+	translateTime time.Duration
+	lastTranslate time.Duration
+
+	backends map[string]*backendStatus
+}
+
+// beginRequest 처리 중인 요청 수를 늘립니다, 반환한 함수로 요청을 기록합니다
+func beginRequest() func(requestRecord) {
+	stats.mu.Lock()
+	stats.active++
+	stats.mu.Unlock()
+
+	return func(record requestRecord) {
+		stats.mu.Lock()
+		defer stats.mu.Unlock()
+
+		stats.active--
+		stats.total++
+		stats.requests = prependLimited(stats.requests, record)
+
+		if record.Error != "" {
+			addError(record.Time, record.Error)
+		}
+	}
+}
+
+// recordError 대시보드에 보여줄 오류를 기록합니다
+func recordError(message string) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	addError(time.Now(), message)
+}
+
+func addError(t time.Time, message string) {
+	stats.errors = append([]errorRecord{{Time: t, Message: message}}, stats.errors...)
+	if len(stats.errors) > recentLimit {
+		stats.errors = stats.errors[:recentLimit]
+	}
+}
+
+// activeRequests 처리 중인 요청 수를 반환합니다
+func activeRequests() int {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	return stats.active
+}
+
+func prependLimited(records []requestRecord, record requestRecord) []requestRecord {
+	records = append([]requestRecord{record}, records...)
+	if len(records) > recentLimit {
+		records = records[:recentLimit]
+	}
+
+	return records
+}
+
+// recordTranslation 번역기를 사용한 결과를 기록합니다
+func recordTranslation(platform string, duration time.Duration, e error) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	if stats.backends == nil {
+		stats.backends = map[string]*backendStatus{}
+	}
+
+	backend, ok := stats.backends[platform]
+	if !ok {
+		backend = &backendStatus{Platform: platform}
+		stats.backends[platform] = backend
+	}
+
+	if e != nil {
+		backend.Healthy = false
+		backend.LastError = time.Now()
+		backend.Error = e.Error()
+		return
+	}
+
+	backend.Healthy = true
+	backend.LastSuccess = time.Now()
+	backend.Error = ""
+
+	stats.translations++
+	stats.translateTime += duration
+	stats.lastTranslate = duration
+}
+
+// requestStats 요청 통계
+type requestStats struct {
+	Active int             `json:"active"`
+	Total  uint64          `json:"total"`
+	Recent []requestRecord `json:"recent"`
+}
+
+// translationStats 번역 시간 통계
+type translationStats struct {
+	Count     uint64  `json:"count"`
+	AverageMS float64 `json:"average_ms"`
+	LastMS    float64 `json:"last_ms"`
+}
+
+// snapshotStats 현재 통계를 복사해서 반환합니다
+func snapshotStats() (requestStats, translationStats, []backendStatus, []errorRecord) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	requests := requestStats{
+		Active: stats.active,
+		Total:  stats.total,
+		Recent: append([]requestRecord{}, stats.requests...),
+	}
+
+	translations := translationStats{
+		Count:  stats.translations,
+		LastMS: milliseconds(stats.lastTranslate),
+	}
+
+	if stats.translations > 0 {
+		translations.AverageMS = milliseconds(stats.translateTime) / float64(stats.translations)
+	}
+
+	backends := make([]backendStatus, 0, len(stats.backends))
+	for _, backend := range stats.backends {
+		backends = append(backends, *backend)
+	}
+
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Platform < backends[j].Platform
+	})
+
+	return requests, translations, backends, append([]errorRecord{}, stats.errors...)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
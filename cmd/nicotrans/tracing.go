@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hype5/nicotrans-go/pkg/trace"
+)
+
+// 스팬을 보내는 익스포터, 설정하지 않았다면 nil
+var traceExporter *trace.OTLPExporter
+
+// initTracing OTLP 수집기 주소가 있다면 스팬을 보내도록 설정합니다
+func initTracing() {
+	if *otlpEndpoint == "" {
+		return
+	}
+
+	traceExporter = trace.NewOTLPExporter(*otlpEndpoint, "nicotrans", *otlpInterval, func(e error) {
+		log.Warningf("트레이스를 보낼 수 없습니다: %s", e)
+	})
+
+	trace.SetExporter(traceExporter)
+
+	// 종료하기 전에 모아둔 트레이스 보내기
+	onShutdown("트레이스 보내기", traceExporter.Shutdown)
+
+	log.Infof("트레이스를 OTLP 수집기로 보냅니다: %s", *otlpEndpoint)
+}
+
+// setServerTiming 단계별 처리 시간을 Server-Timing 헤더로 알려줍니다
+// 브라우저 개발자 도구의 네트워크 탭에서 볼 수 있습니다
+func setServerTiming(w http.ResponseWriter, record requestRecord) {
+	var timings []string
+
+	for _, stage := range []struct {
+		name     string
+		duration float64
+	}{
+		{"upstream", record.UpstreamMS},
+		{"translate", record.TranslateMS},
+		{"encode", record.EncodeMS},
+		{"total", record.DurationMS},
+	} {
+		if stage.duration > 0 {
+			timings = append(timings, fmt.Sprintf("%s;dur=%.1f", stage.name, stage.duration))
+		}
+	}
+
+	if len(timings) > 0 {
+		w.Header().Set("Server-Timing", strings.Join(timings, ", "))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hype5/nicotrans-go/pkg/certificate"
+	"github.com/hype5/nicotrans-go/pkg/hosts"
+	"github.com/hype5/nicotrans-go/pkg/nico"
+)
+
+// runUninstall 니코트랜스가 설치한 인증서와 호스트 항목을 모두 제거합니다
+func runUninstall(args []string) error {
+	fs := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	deleteCert := fs.Bool("delete-cert", false, "인증서 파일도 삭제할지?")
+	fs.Parse(args)
+
+	state, e := loadState(*statePath)
+	if e != nil {
+		return fmt.Errorf("상태 파일을 불러올 수 없습니다: %s", e)
+	}
+
+	// 상태 파일이 없는 이전 버전이라면 현재 인증서와 호스트 항목으로 찾기
+	if state.empty() {
+		fmt.Println("상태 파일이 없어 현재 인증서와 호스트 항목을 찾아 제거합니다")
+		guessState(state)
+	}
+
+	failed := false
+
+	// 인증서 제거하기
+	var certificates []installedCertificate
+	for _, c := range state.Certificates {
+		cert, e := x509.ParseCertificate(c.Certificate)
+		if e != nil {
+			fmt.Printf("[%s] 기록된 인증서가 잘못됐습니다: %s\n", c.Store, e)
+			continue
+		}
+
+		store := certificate.FindStore(c.Store)
+		if store == nil {
+			fmt.Printf("[%s] 저장소가 더 이상 존재하지 않습니다\n", c.Store)
+			continue
+		}
+
+		if e := store.Uninstall(cert); e != nil {
+			fmt.Printf("[%s] 인증서를 제거할 수 없습니다: %s\n", c.Store, e)
+			certificates = append(certificates, c)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("[%s] 인증서를 제거했습니다\n", c.Store)
+	}
+
+	state.Certificates = certificates
+
+	// 호스트 항목 제거하기
+	if len(state.Hosts) > 0 {
+		if e := removeHosts(state.Hosts); e != nil {
+			fmt.Printf("호스트 파일에서 항목을 제거할 수 없습니다: %s\n", e)
+			failed = true
+		} else {
+			for _, h := range state.Hosts {
+				fmt.Printf("호스트 파일에서 %s %s 항목을 제거했습니다\n", h.IP, h.Host)
+			}
+
+			state.Hosts = nil
+		}
+	}
+
+	if *deleteCert {
+		for _, path := range []string{*certPath, *certPrivPath, *certExportDER, *certExportPKCS12} {
+			if path == "" {
+				continue
+			}
+
+			if e := os.Remove(path); e != nil && !os.IsNotExist(e) {
+				fmt.Printf("%s 파일을 삭제할 수 없습니다: %s\n", path, e)
+				failed = true
+			} else {
+				fmt.Printf("%s 파일을 삭제했습니다\n", path)
+			}
+		}
+	}
+
+	if e := state.save(); e != nil {
+		return fmt.Errorf("상태 파일을 저장할 수 없습니다: %s", e)
+	}
+
+	if failed {
+		return fmt.Errorf("일부 항목을 제거하지 못했습니다, 관리자 권한으로 다시 실행해주세요")
+	}
+
+	return nil
+}
+
+// guessState 현재 인증서가 설치된 저장소와 호스트 항목을 상태에 채웁니다
+func guessState(state *installState) {
+	if cert, _, e := certificate.ImportWithPassword(*certPath, *certPrivPath, keyPassword()); e == nil {
+		for _, store := range certificate.Stores() {
+			if ok, _ := store.Contains(cert); ok {
+				state.addCertificate(store.Name(), cert.Raw)
+			}
+		}
+	}
+
+	if file, e := hosts.Load(*hostsPath); e == nil {
+		for _, entry := range file.Block() {
+			state.addHost(entry.IP, entry.Host)
+		}
+
+		// 블록을 사용하기 전 버전이 추가한 항목
+		for _, host := range nico.Hosts {
+			if file.Has(*serverIP, host) {
+				state.addHost(*serverIP, host)
+			}
+		}
+	}
+}
+
+func removeHosts(entries []installedHost) error {
+	file, e := hosts.Load(*hostsPath)
+	if e != nil {
+		return e
+	}
+
+	for _, h := range entries {
+		file.Remove(h.IP, h.Host)
+	}
+
+	// 남은 항목이 없다면 블록 주석도 지우기
+	file.SetBlock(file.Block(), file.Owner())
+
+	return file.Save()
+}
@@ -3,8 +3,10 @@ module github.com/hype5/nicotrans-go
 go 1.14
 
 require (
-	gitea.chriswiegman.com/chriswiegman/goodhosts v2.1.0+incompatible
 	github.com/miekg/dns v1.1.29
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae
+	gopkg.in/yaml.v2 v2.4.0
+	software.sslmate.com/src/go-pkcs12 v0.0.0-20200830195227-52f69702a001
 )
@@ -2,6 +2,7 @@ package certificate
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,7 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"os"
+	"math/big"
+
+	"github.com/hype5/nicotrans-go/pkg/fileutil"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 func exportPublicKey(priv interface{}) (interface{}, error) {
@@ -18,48 +22,63 @@ func exportPublicKey(priv interface{}) (interface{}, error) {
 		return &k.PublicKey, nil
 	case *ecdsa.PrivateKey:
 		return &k.PublicKey, nil
+	case ed25519.PrivateKey:
+		return k.Public(), nil
 	default:
 		return nil, fmt.Errorf("Unsupported key: %T", k)
 	}
 }
 
-func exportPemBlock(priv interface{}) (*pem.Block, error) {
-	switch k := priv.(type) {
-	case *rsa.PrivateKey:
-		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
-	case *ecdsa.PrivateKey:
-		b, e := x509.MarshalECPrivateKey(k)
-		if e != nil {
-			return nil, e
-		}
+// Create 메소드는 자체 서명한 인증서와 키를 생성합니다
+func Create(template *x509.Certificate) (*x509.Certificate, interface{}, error) {
+	return Sign(template, nil, nil)
+}
 
-		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}, nil
-	default:
-		return nil, fmt.Errorf("Unsupported key: %T", k)
+// CreateWithKey 주어진 키로 자체 서명한 인증서를 생성합니다
+func CreateWithKey(template *x509.Certificate, priv interface{}) (*x509.Certificate, interface{}, error) {
+	pub, e := exportPublicKey(priv)
+	if e != nil {
+		return nil, nil, e
 	}
+
+	return sign(template, priv, pub, nil, nil)
 }
 
-// Create 메소드는 인증서와 키를 생성합니다
-func Create(template *x509.Certificate) (*x509.Certificate, interface{}, error) {
-	priv, e := rsa.GenerateKey(rand.Reader, 2048)
+// Sign 새 키를 생성하고 상위 인증서로 서명한 인증서를 만듭니다
+// 상위 인증서가 nil 이라면 자체 서명합니다
+func Sign(template *x509.Certificate, parent *x509.Certificate, parentPriv interface{}) (*x509.Certificate, interface{}, error) {
+	priv, e := GenerateKey(RSA, 0)
 	if e != nil {
 		return nil, nil, e
 	}
 
-	// template := &x509.Certificate{
-	// 	SerialNumber: big.NewInt(1),
-	// 	Subject: pkix.Name{
-	// 		Organization: []string{"NicoTrans"},
-	// 	},
-	// 	DNSNames:    names,
-	// 	NotBefore:   time.Now(),
-	// 	NotAfter:    time.Now().AddDate(10, 0, 0),
-	// 	KeyUsage:    x509.KeyUsageDigitalSignature,
-	// 	ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-	// 	IsCA:        true,
-	// }
-
-	certBytes, e := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	pub, e := exportPublicKey(priv)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	return sign(template, priv, pub, parent, parentPriv)
+}
+
+func sign(template *x509.Certificate, priv interface{}, pub interface{}, parent *x509.Certificate, parentPriv interface{}) (*x509.Certificate, interface{}, error) {
+	// 일련번호가 없다면 템플릿을 복사해서 채우기
+	if template.SerialNumber == nil {
+		serial, e := serialNumber()
+		if e != nil {
+			return nil, nil, e
+		}
+
+		t := *template
+		t.SerialNumber = serial
+		template = &t
+	}
+
+	if parent == nil {
+		parent = template
+		parentPriv = priv
+	}
+
+	certBytes, e := x509.CreateCertificate(rand.Reader, template, parent, pub, parentPriv)
 	if e != nil {
 		return nil, nil, e
 	}
@@ -72,43 +91,73 @@ func Create(template *x509.Certificate) (*x509.Certificate, interface{}, error)
 	return cert, priv, nil
 }
 
-// Export 인증서와 키를 저장합니다
-func Export(cert *x509.Certificate, priv interface{}, certPath string, privPath string) error {
-	// 저장될 파일 열기
-	certFile, e := os.OpenFile(certPath, os.O_CREATE|os.O_WRONLY, 0600)
-	if e != nil {
-		return e
-	}
+// serialNumber 무작위 128비트 일련번호를 만듭니다
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
 
-	defer certFile.Close()
+// IsCA 다른 인증서를 서명할 수 있는 인증서인지?
+func IsCA(cert *x509.Certificate) bool {
+	return cert.IsCA && cert.BasicConstraintsValid && cert.KeyUsage&x509.KeyUsageCertSign != 0
+}
 
-	privFile, e := os.OpenFile(privPath, os.O_CREATE|os.O_WRONLY, 0600)
-	if e != nil {
-		return e
-	}
+// ExportOptions 개인 키를 저장할 형식
+type ExportOptions struct {
+	// PKCS#8 형식으로 저장할지?
+	PKCS8 bool
+	// 비어있지 않다면 이 비밀번호로 암호화합니다
+	Password []byte
+}
 
-	defer privFile.Close()
+// Export 인증서와 키를 저장합니다
+func Export(cert *x509.Certificate, priv interface{}, certPath string, privPath string) error {
+	return ExportWithOptions(cert, priv, certPath, privPath, ExportOptions{})
+}
 
+// ExportWithOptions 인증서와 키를 주어진 형식으로 저장합니다
+// 임시 파일에 먼저 쓴 뒤 이름을 바꾸기 때문에 저장 중에 중단되더라도 이전 파일이 그대로 남습니다
+func ExportWithOptions(cert *x509.Certificate, priv interface{}, certPath string, privPath string, options ExportOptions) error {
 	// PEM 블록 만들기
-	privBlock, e := exportPemBlock(priv)
+	privBlock, e := MarshalPrivateKey(priv, options.PKCS8, options.Password)
 	if e != nil {
 		return e
 	}
 
-	// 저장하기
-	if e := pem.Encode(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); e != nil {
+	// 저장하기, 키를 먼저 저장해야 인증서만 바뀌어 짝이 맞지 않는 일이 없음
+	if e := fileutil.WriteAtomic(privPath, pem.EncodeToMemory(privBlock), 0600); e != nil {
 		return e
 	}
 
-	if e := pem.Encode(privFile, privBlock); e != nil {
+	if e := fileutil.WriteAtomic(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); e != nil {
 		return e
 	}
 
 	return nil
 }
 
+// ExportDER 다른 기기에 설치할 수 있도록 인증서를 DER 형식으로 저장합니다
+func ExportDER(cert *x509.Certificate, path string) error {
+	return fileutil.WriteAtomic(path, cert.Raw, 0644)
+}
+
+// ExportPKCS12 다른 기기가 신뢰하도록 인증서만 PKCS#12 (.p12, .pfx) 신뢰 저장소 형식으로 저장합니다
+// 키가 있으면 가로채는 호스트의 인증서를 만들 수 있으므로 키는 넣지 않고, 공개해도 되는 파일이라 비밀번호도 쓰지 않습니다
+func ExportPKCS12(cert *x509.Certificate, path string) error {
+	data, e := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{cert}, "")
+	if e != nil {
+		return e
+	}
+
+	return fileutil.WriteAtomic(path, data, 0644)
+}
+
 // Import 인증서와 키를 파일에서 불러옵니다
 func Import(certPath string, privPath string) (*x509.Certificate, interface{}, error) {
+	return ImportWithPassword(certPath, privPath, nil)
+}
+
+// ImportWithPassword 인증서와 암호화된 키를 파일에서 불러옵니다
+func ImportWithPassword(certPath string, privPath string, password []byte) (*x509.Certificate, interface{}, error) {
 	// 파일 불러오기
 	certFile, e := ioutil.ReadFile(certPath)
 	if e != nil {
@@ -120,13 +169,18 @@ func Import(certPath string, privPath string) (*x509.Certificate, interface{}, e
 		return nil, nil, e
 	}
 
+	return Parse(certFile, privFile, password)
+}
+
+// Parse PEM 형식의 인증서와 키를 불러옵니다
+func Parse(certPEM []byte, privPEM []byte, password []byte) (*x509.Certificate, interface{}, error) {
 	// PEM 블록 디코딩하기
-	certBlock, _ := pem.Decode(certFile)
+	certBlock, _ := pem.Decode(certPEM)
 	if certBlock == nil {
 		return nil, nil, errors.New("인증서의 PEM 블록이 잘못됐습니다")
 	}
 
-	privBlock, _ := pem.Decode(privFile)
+	privBlock, _ := pem.Decode(privPEM)
 	if privBlock == nil {
 		return nil, nil, errors.New("개인 키의 PEM 블록이 잘못됐습니다")
 	}
@@ -137,17 +191,7 @@ func Import(certPath string, privPath string) (*x509.Certificate, interface{}, e
 		return nil, nil, e
 	}
 
-	var priv interface{}
-
-	switch privBlock.Type {
-	case "RSA PRIVATE KEY":
-		priv, e = x509.ParsePKCS1PrivateKey(privBlock.Bytes)
-	case "EC PRIVATE KEY":
-		priv, e = x509.ParseECPrivateKey(privBlock.Bytes)
-	default:
-		e = fmt.Errorf("Unsupported key: %T", priv)
-	}
-
+	priv, e := ParsePrivateKey(privBlock, password)
 	if e != nil {
 		return nil, nil, e
 	}
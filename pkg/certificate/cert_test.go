@@ -0,0 +1,62 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestExportPKCS12(t *testing.T) {
+	priv, e := GenerateKey(ECDSA, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "nicotrans 테스트"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	cert, _, e := CreateWithKey(template, priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	path := filepath.Join(t.TempDir(), "nicotrans.p12")
+
+	if e := ExportPKCS12(cert, path); e != nil {
+		t.Fatal(e)
+	}
+
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	certs, e := pkcs12.DecodeTrustStore(data, "")
+	if e != nil || len(certs) != 1 || !certs[0].Equal(cert) {
+		t.Fatalf("인증서 %d개 (%v)", len(certs), e)
+	}
+
+	// 다른 기기에 공유하는 파일이니 키가 없어야 함
+	if _, _, e := pkcs12.Decode(data, ""); e == nil {
+		t.Error("PKCS#12 파일에 키가 들어있습니다")
+	}
+
+	if info, e := os.Stat(path); e != nil {
+		t.Error(e)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Errorf("권한 %v, 기대값 %v", info.Mode().Perm(), os.FileMode(0644))
+	}
+}
@@ -1,11 +1,323 @@
 package certificate
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
 )
 
-// InstallAsRootCA 인증서를 신뢰할 수 있는 루트 인증 기관으로 설치합니다
-func InstallAsRootCA(cert *x509.Certificate) (bool, error) {
-	return false, fmt.Errorf("윈도우 환경에서만 사용할 수 있는 메소드입니다")
+// 시스템 저장소에 저장할 인증서 파일 이름
+var systemCertName = "nicotrans.crt"
+
+// systemStore 배포판의 시스템 인증서 저장소
+type systemStore struct {
+	name   string
+	dir    string
+	update []string
+}
+
+// 배포판별 시스템 인증서 저장소 구조
+var systemStores = []systemStore{
+	{
+		// 데비안, 우분투
+		name:   "데비안 시스템 인증서 저장소",
+		dir:    "/usr/local/share/ca-certificates",
+		update: []string{"update-ca-certificates"},
+	},
+	{
+		// 페도라, RHEL, CentOS
+		name:   "페도라 시스템 인증서 저장소",
+		dir:    "/etc/pki/ca-trust/source/anchors",
+		update: []string{"update-ca-trust", "extract"},
+	},
+}
+
+// Stores 인증서를 설치할 수 있는 저장소 목록을 반환합니다
+func Stores() []Store {
+	var stores []Store
+
+	for _, s := range systemStores {
+		if _, e := lookPath(s.update[0]); e != nil {
+			continue
+		}
+
+		if info, e := os.Stat(s.dir); e != nil || !info.IsDir() {
+			continue
+		}
+
+		stores = append(stores, s)
+	}
+
+	// NSS 데이터베이스는 certutil 이 있어야 수정할 수 있음
+	if certutil, e := lookPath("certutil"); e == nil {
+		for _, dir := range nssDatabases() {
+			stores = append(stores, nssStore{dir: dir, certutil: certutil})
+		}
+	}
+
+	return stores
+}
+
+func (s systemStore) Name() string {
+	return s.name
+}
+
+func (s systemStore) path() string {
+	return filepath.Join(s.dir, systemCertName)
+}
+
+func (s systemStore) Plan(cert *x509.Certificate) []string {
+	return []string{
+		fmt.Sprintf("%s 파일에 인증서를 저장합니다", s.path()),
+		fmt.Sprintf("%s 명령을 실행합니다", strings.Join(s.update, " ")),
+	}
+}
+
+func (s systemStore) Contains(cert *x509.Certificate) (bool, error) {
+	data, e := ioutil.ReadFile(s.path())
+	if os.IsNotExist(e) {
+		return false, nil
+	} else if e != nil {
+		return false, e
+	}
+
+	block, _ := pem.Decode(data)
+
+	return block != nil && bytes.Equal(block.Bytes, cert.Raw), nil
+}
+
+func (s systemStore) Install(cert *x509.Certificate) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if e := ioutil.WriteFile(s.path(), data, 0644); e != nil {
+		return e
+	}
+
+	return run(s.update...)
+}
+
+func (s systemStore) Uninstall(cert *x509.Certificate) error {
+	if ok, e := s.Contains(cert); e != nil || !ok {
+		return e
+	}
+
+	if e := os.Remove(s.path()); e != nil {
+		return e
+	}
+
+	return run(s.update...)
+}
+
+// nssStore 파이어폭스, 크로미움이 사용하는 NSS 인증서 데이터베이스
+type nssStore struct {
+	dir      string
+	certutil string
+}
+
+func (s nssStore) Name() string {
+	return "NSS 데이터베이스 " + s.dir
+}
+
+// nickname 인증서를 구분할 수 있도록 이름 뒤에 SHA-256 지문 앞부분을 붙입니다
+// 인증서를 교체해도 이전 인증서와 이름이 겹치지 않습니다
+func (s nssStore) nickname(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+
+	return fmt.Sprintf("%s %X", s.legacyNickname(cert), sum[:8])
+}
+
+// legacyNickname 지문을 붙이지 않던 이전 버전이 사용한 이름
+func (s nssStore) legacyNickname(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	return "NicoTrans"
+}
+
+// args 명령 뒤에 데이터베이스 폴더를 붙인 certutil 인자를 만듭니다
+func (s nssStore) args(args ...string) []string {
+	return append(args, "-d", "sql:"+s.dir)
+}
+
+// addArgs 인증서를 신뢰하는 루트 인증서로 추가하는 certutil 인자를 만듭니다
+func (s nssStore) addArgs(cert *x509.Certificate) []string {
+	return s.args("-A", "-t", "C,,", "-n", s.nickname(cert))
+}
+
+// listArgs 이름으로 찾은 인증서를 DER 형식으로 출력하는 certutil 인자를 만듭니다
+func (s nssStore) listArgs(nickname string) []string {
+	return s.args("-L", "-n", nickname, "-r")
+}
+
+// deleteArgs 이름으로 찾은 인증서를 지우는 certutil 인자를 만듭니다
+func (s nssStore) deleteArgs(nickname string) []string {
+	return s.args("-D", "-n", nickname)
+}
+
+// command 데이터베이스 폴더의 소유자로 certutil 을 실행하는 명령을 만듭니다
+// 관리자 권한으로 실행해도 사용자의 데이터베이스에 관리자 소유의 파일이 생기지 않습니다
+func (s nssStore) command(args []string) *exec.Cmd {
+	cmd := exec.Command(s.certutil, args...)
+
+	if info, e := os.Stat(s.dir); e == nil {
+		if credential := ownerCredential(info, os.Geteuid()); credential != nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+		}
+	}
+
+	return cmd
+}
+
+// ownerCredential 관리자 권한으로 실행 중이고 폴더의 소유자가 다른 사용자라면 그 사용자의 권한을 반환합니다
+func ownerCredential(info os.FileInfo, euid int) *syscall.Credential {
+	if euid != 0 {
+		return nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid == 0 {
+		return nil
+	}
+
+	return &syscall.Credential{Uid: stat.Uid, Gid: stat.Gid}
+}
+
+// commandLine 사용자에게 보여줄 명령을 만듭니다, 공백이 있는 인자는 따옴표로 감쌉니다
+func commandLine(name string, args []string) string {
+	quoted := []string{name}
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t\"") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// find 인증서가 설치된 이름을 찾습니다, 이름이 같더라도 다른 인증서라면 찾지 않습니다
+func (s nssStore) find(cert *x509.Certificate) string {
+	for _, nickname := range []string{s.nickname(cert), s.legacyNickname(cert)} {
+		// 이름이 같은 인증서가 없으면 실패하기 때문에 오류는 무시함
+		out, e := s.command(s.listArgs(nickname)).Output()
+		if e == nil && bytes.Equal(out, cert.Raw) {
+			return nickname
+		}
+	}
+
+	return ""
+}
+
+func (s nssStore) Plan(cert *x509.Certificate) []string {
+	return []string{
+		fmt.Sprintf("%s 명령으로 인증서를 추가합니다", commandLine(s.certutil, s.addArgs(cert))),
+	}
+}
+
+func (s nssStore) Contains(cert *x509.Certificate) (bool, error) {
+	return s.find(cert) != "", nil
+}
+
+func (s nssStore) Install(cert *x509.Certificate) error {
+	cmd := s.command(s.addArgs(cert))
+	cmd.Stdin = bytes.NewReader(cert.Raw)
+
+	if out, e := cmd.CombinedOutput(); e != nil {
+		return fmt.Errorf("%s: %s", e, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (s nssStore) Uninstall(cert *x509.Certificate) error {
+	nickname := s.find(cert)
+	if nickname == "" {
+		return nil
+	}
+
+	if out, e := s.command(s.deleteArgs(nickname)).CombinedOutput(); e != nil {
+		return fmt.Errorf("%s: %s", e, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// nssDatabases 사용자의 NSS 데이터베이스 (cert9.db) 가 있는 폴더를 찾습니다
+func nssDatabases() []string {
+	home := homeDir()
+	if home == "" {
+		return nil
+	}
+
+	patterns := []string{
+		// 크롬, 크로미움
+		filepath.Join(home, ".pki", "nssdb"),
+		filepath.Join(home, "snap", "chromium", "current", ".pki", "nssdb"),
+		// 파이어폭스 프로필
+		filepath.Join(home, ".mozilla", "firefox", "*"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "*"),
+	}
+
+	var dirs []string
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(pattern, "cert9.db"))
+		for _, match := range matches {
+			dirs = append(dirs, filepath.Dir(match))
+		}
+	}
+
+	return dirs
+}
+
+// homeDir sudo 로 실행했다면 원래 사용자의 홈 폴더를 반환합니다
+func homeDir() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		if u, e := user.Lookup(name); e == nil {
+			return u.HomeDir
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+
+	return home
+}
+
+// lookPath PATH 에 sbin 폴더가 없는 일반 사용자 환경에서도 명령을 찾습니다
+func lookPath(name string) (string, error) {
+	if path, e := exec.LookPath(name); e == nil {
+		return path, nil
+	}
+
+	for _, dir := range []string{"/usr/sbin", "/sbin", "/usr/bin"} {
+		path := filepath.Join(dir, name)
+		if info, e := os.Stat(path); e == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s 명령을 찾을 수 없습니다", name)
+}
+
+func run(args ...string) error {
+	path, e := lookPath(args[0])
+	if e != nil {
+		return e
+	}
+
+	if out, e := exec.Command(path, args[1:]...).CombinedOutput(); e != nil {
+		return fmt.Errorf("%s: %s", e, strings.TrimSpace(string(out)))
+	}
+
+	return nil
 }
@@ -0,0 +1,289 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testCertificate 주어진 이름으로 자체 서명한 루트 인증서를 만듭니다
+func testCertificate(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+
+	priv, e := GenerateKey(ECDSA, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, _, e := CreateWithKey(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return cert
+}
+
+func TestSystemStorePath(t *testing.T) {
+	for _, s := range systemStores {
+		want := filepath.Join(s.dir, "nicotrans.crt")
+		if got := s.path(); got != want {
+			t.Errorf("%s: %s, 기대값 %s", s.name, got, want)
+		}
+
+		// update-ca-certificates 는 .crt 확장자가 아닌 파일을 무시함
+		if filepath.Ext(s.path()) != ".crt" {
+			t.Errorf("%s: %s 파일의 확장자가 .crt 가 아닙니다", s.name, s.path())
+		}
+	}
+}
+
+func TestSystemStoreContains(t *testing.T) {
+	cert := testCertificate(t, "NicoTrans Root CA")
+	other := testCertificate(t, "NicoTrans Root CA")
+
+	s := systemStore{name: "테스트", dir: t.TempDir()}
+
+	if ok, e := s.Contains(cert); e != nil || ok {
+		t.Errorf("파일이 없을 때 %v %v, 기대값 false", ok, e)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if e := ioutil.WriteFile(s.path(), data, 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	if ok, e := s.Contains(cert); e != nil || !ok {
+		t.Errorf("저장한 인증서가 %v %v, 기대값 true", ok, e)
+	}
+
+	if ok, e := s.Contains(other); e != nil || ok {
+		t.Errorf("다른 인증서가 %v %v, 기대값 false", ok, e)
+	}
+}
+
+func TestNSSNickname(t *testing.T) {
+	s := nssStore{dir: "/home/nico/.pki/nssdb", certutil: "certutil"}
+
+	tests := []struct {
+		commonName string
+		legacy     string
+	}{
+		{"NicoTrans Root CA", "NicoTrans Root CA"},
+		{"", "NicoTrans"},
+	}
+
+	for _, test := range tests {
+		cert := testCertificate(t, test.commonName)
+
+		if got := s.legacyNickname(cert); got != test.legacy {
+			t.Errorf("%q: 이전 이름 %q, 기대값 %q", test.commonName, got, test.legacy)
+		}
+
+		// 이전 이름 뒤에 SHA-256 지문 앞 8바이트를 붙임
+		pattern := "^" + regexp.QuoteMeta(test.legacy) + " [0-9A-F]{16}$"
+		if got := s.nickname(cert); !regexp.MustCompile(pattern).MatchString(got) {
+			t.Errorf("%q: 이름 %q 이 %s 형식이 아닙니다", test.commonName, got, pattern)
+		}
+	}
+
+	// 이름이 같은 인증서로 교체해도 이름이 겹치지 않아야 함
+	a, b := testCertificate(t, "NicoTrans Root CA"), testCertificate(t, "NicoTrans Root CA")
+	if s.nickname(a) == s.nickname(b) {
+		t.Errorf("다른 인증서의 이름이 %q 으로 같습니다", s.nickname(a))
+	}
+
+	if s.nickname(a) != s.nickname(a) {
+		t.Error("같은 인증서의 이름이 다릅니다")
+	}
+}
+
+func TestNSSArgs(t *testing.T) {
+	s := nssStore{dir: "/home/nico/.pki/nssdb", certutil: "/usr/bin/certutil"}
+	cert := testCertificate(t, "NicoTrans Root CA")
+	nickname := s.nickname(cert)
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"추가", s.addArgs(cert), []string{"-A", "-t", "C,,", "-n", nickname, "-d", "sql:/home/nico/.pki/nssdb"}},
+		{"찾기", s.listArgs("NicoTrans Root CA"), []string{"-L", "-n", "NicoTrans Root CA", "-r", "-d", "sql:/home/nico/.pki/nssdb"}},
+		{"지우기", s.deleteArgs(nickname), []string{"-D", "-n", nickname, "-d", "sql:/home/nico/.pki/nssdb"}},
+	}
+
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: %q, 기대값 %q", test.name, test.got, test.want)
+		}
+	}
+
+	want := `/usr/bin/certutil -A -t C,, -n "` + nickname + `" -d sql:/home/nico/.pki/nssdb 명령으로 인증서를 추가합니다`
+	if plan := s.Plan(cert); len(plan) != 1 || plan[0] != want {
+		t.Errorf("%q, 기대값 [%q]", plan, want)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "certutil"},
+		{[]string{"-L", "-d", "sql:/tmp/nssdb"}, "certutil -L -d sql:/tmp/nssdb"},
+		{[]string{"-n", "NicoTrans Root CA"}, `certutil -n "NicoTrans Root CA"`},
+		{[]string{"-n", `Nico"Trans`}, `certutil -n "Nico\"Trans"`},
+	}
+
+	for _, test := range tests {
+		if got := commandLine("certutil", test.args); got != test.want {
+			t.Errorf("%q: %s, 기대값 %s", test.args, got, test.want)
+		}
+	}
+}
+
+// ownedFile 소유자만 정한 가짜 파일 정보
+type ownedFile struct {
+	os.FileInfo
+	uid uint32
+	gid uint32
+}
+
+func (f ownedFile) Sys() interface{} {
+	return &syscall.Stat_t{Uid: f.uid, Gid: f.gid}
+}
+
+func TestOwnerCredential(t *testing.T) {
+	tests := []struct {
+		name string
+		info os.FileInfo
+		euid int
+		want *syscall.Credential
+	}{
+		{"관리자가 사용자의 폴더", ownedFile{uid: 1000, gid: 1001}, 0, &syscall.Credential{Uid: 1000, Gid: 1001}},
+		{"관리자가 관리자의 폴더", ownedFile{uid: 0, gid: 0}, 0, nil},
+		{"사용자가 사용자의 폴더", ownedFile{uid: 1000, gid: 1001}, 1000, nil},
+		{"사용자가 다른 사용자의 폴더", ownedFile{uid: 1002, gid: 1002}, 1000, nil},
+	}
+
+	for _, test := range tests {
+		if got := ownerCredential(test.info, test.euid); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %+v, 기대값 %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNSSDatabases(t *testing.T) {
+	home := t.TempDir()
+
+	for key, value := range map[string]string{"HOME": home, "SUDO_USER": ""} {
+		previous, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+
+		defer func(key string) {
+			if ok {
+				os.Setenv(key, previous)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key)
+	}
+
+	databases := []string{
+		filepath.Join(home, ".pki", "nssdb"),
+		filepath.Join(home, ".mozilla", "firefox", "abcd1234.default-release"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "efgh5678.default"),
+	}
+
+	for _, dir := range databases {
+		if e := os.MkdirAll(dir, 0700); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := ioutil.WriteFile(filepath.Join(dir, "cert9.db"), nil, 0600); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	// 이전 형식 (cert8.db) 만 있는 프로필은 찾지 않음
+	legacy := filepath.Join(home, ".mozilla", "firefox", "old.default")
+	if e := os.MkdirAll(legacy, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := ioutil.WriteFile(filepath.Join(legacy, "cert8.db"), nil, 0600); e != nil {
+		t.Fatal(e)
+	}
+
+	got := nssDatabases()
+	sort.Strings(got)
+	sort.Strings(databases)
+
+	if !reflect.DeepEqual(got, databases) {
+		t.Errorf("%q, 기대값 %q", got, databases)
+	}
+}
+
+// TestNSSStoreCommands 가짜 certutil 로 설치와 삭제가 실행하는 명령을 확인합니다
+func TestNSSStoreCommands(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "certutil.log")
+
+	// 인증서를 찾는 명령은 항상 실패하는 certutil
+	certutil := filepath.Join(dir, "certutil")
+	script := "#!/bin/sh\necho \"$*\" >> " + log + "\n[ \"$1\" = \"-L\" ] && exit 255\nexit 0\n"
+	if e := ioutil.WriteFile(certutil, []byte(script), 0755); e != nil {
+		t.Fatal(e)
+	}
+
+	s := nssStore{dir: filepath.Join(dir, "nssdb"), certutil: certutil}
+	cert := testCertificate(t, "NicoTrans")
+
+	if e := s.Install(cert); e != nil {
+		t.Fatal(e)
+	}
+
+	if ok, e := s.Contains(cert); e != nil || ok {
+		t.Errorf("%v %v, 기대값 false", ok, e)
+	}
+
+	// 설치된 인증서를 찾지 못했으므로 지우지 않음
+	if e := s.Uninstall(cert); e != nil {
+		t.Fatal(e)
+	}
+
+	data, e := ioutil.ReadFile(log)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var want []string
+	for _, args := range [][]string{
+		s.addArgs(cert),
+		s.listArgs(s.nickname(cert)), s.listArgs(s.legacyNickname(cert)),
+		s.listArgs(s.nickname(cert)), s.listArgs(s.legacyNickname(cert)),
+	} {
+		want = append(want, strings.Join(args, " "))
+	}
+
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("%q, 기대값 %q", got, want)
+	}
+}
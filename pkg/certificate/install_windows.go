@@ -1,6 +1,7 @@
 package certificate
 
 import (
+	"bytes"
 	"crypto/x509"
 	"syscall"
 	"unsafe"
@@ -11,23 +12,102 @@ import (
 var (
 	crypt32                              = syscall.NewLazyDLL("crypt32.dll")
 	procCertAddEncodedCertificateToStore = crypt32.NewProc("CertAddEncodedCertificateToStore")
+	procCertDeleteCertificateFromStore   = crypt32.NewProc("CertDeleteCertificateFromStore")
 )
 
-// InstallAsRootCA 인증서를 신뢰할 수 있는 루트 인증 기관으로 설치합니다
-func InstallAsRootCA(cert *x509.Certificate) (bool, error) {
-	store, e := syscall.CertOpenStore(
+// windowsStore 현재 사용자의 신뢰할 수 있는 루트 인증 기관 저장소
+type windowsStore struct{}
+
+// Stores 인증서를 설치할 수 있는 저장소 목록을 반환합니다
+func Stores() []Store {
+	return []Store{windowsStore{}}
+}
+
+func (windowsStore) Name() string {
+	return "윈도우 사용자 루트 인증서 저장소"
+}
+
+func (windowsStore) Plan(cert *x509.Certificate) []string {
+	return []string{"현재 사용자의 신뢰할 수 있는 루트 인증 기관에 " + cert.Subject.CommonName + " 인증서를 추가합니다"}
+}
+
+func openRootStore() (syscall.Handle, error) {
+	return syscall.CertOpenStore(
 		windows.CERT_STORE_PROV_SYSTEM, // LPCSTR lpszStoreProvider
 		0,                              // DWORD dwEncodingType
 		0,                              // HCRYPTPROV_LEGACY hCryptProv
 		windows.CERT_STORE_OPEN_EXISTING_FLAG|windows.CERT_SYSTEM_STORE_CURRENT_USER, // DWORD dwFlags
 		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("root"))),                    // *pvPara
 	)
+}
+
+func (windowsStore) Contains(cert *x509.Certificate) (bool, error) {
+	store, e := openRootStore()
 	if e != nil {
 		return false, e
 	}
 
 	defer syscall.CertCloseStore(store, 0)
 
+	ctx := findCertificate(store, cert)
+	if ctx == nil {
+		return false, nil
+	}
+
+	syscall.CertFreeCertificateContext(ctx)
+
+	return true, nil
+}
+
+// findCertificate 저장소에서 인증서를 찾습니다, 찾은 컨텍스트는 직접 해제해야합니다
+func findCertificate(store syscall.Handle, cert *x509.Certificate) *syscall.CertContext {
+	var ctx *syscall.CertContext
+	var e error
+
+	for {
+		ctx, e = syscall.CertEnumCertificatesInStore(store, ctx)
+		if e != nil || ctx == nil {
+			// 더 이상 인증서가 없음
+			return nil
+		}
+
+		encoded := (*[1 << 20]byte)(unsafe.Pointer(ctx.EncodedCert))[:ctx.Length:ctx.Length]
+		if bytes.Equal(encoded, cert.Raw) {
+			return ctx
+		}
+	}
+}
+
+func (windowsStore) Uninstall(cert *x509.Certificate) error {
+	store, e := openRootStore()
+	if e != nil {
+		return e
+	}
+
+	defer syscall.CertCloseStore(store, 0)
+
+	ctx := findCertificate(store, cert)
+	if ctx == nil {
+		return nil
+	}
+
+	// 성공 여부와 상관없이 컨텍스트는 해제됨
+	r, _, e := procCertDeleteCertificateFromStore.Call(uintptr(unsafe.Pointer(ctx)))
+	if r == 0 {
+		return e
+	}
+
+	return nil
+}
+
+func (windowsStore) Install(cert *x509.Certificate) error {
+	store, e := openRootStore()
+	if e != nil {
+		return e
+	}
+
+	defer syscall.CertCloseStore(store, 0)
+
 	r, _, e := procCertAddEncodedCertificateToStore.Call(
 		uintptr(store), // HCERTSTORE hCertStore
 		uintptr(windows.X509_ASN_ENCODING|windows.PKCS_7_ASN_ENCODING), // DWORD dwCertEncodingType
@@ -38,11 +118,11 @@ func InstallAsRootCA(cert *x509.Certificate) (bool, error) {
 	)
 	if r == 0 {
 		if uintptr(e.(syscall.Errno)) == uintptr(windows.CRYPT_E_EXISTS) {
-			return true, nil
+			return nil
 		}
 
-		return false, e
+		return e
 	}
 
-	return false, nil
+	return nil
 }
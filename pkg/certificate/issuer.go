@@ -0,0 +1,154 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLeafValidity 호스트별 인증서의 기본 유효 기간
+var DefaultLeafValidity = 7 * 24 * time.Hour
+
+// Issuer 루트 인증서로 호스트별 인증서를 필요할 때 발급하고 메모리에 캐시합니다
+type Issuer struct {
+	// 발급할 인증서의 유효 기간
+	Validity time.Duration
+	// 인증서를 발급해도 되는 호스트인지? nil 이면 모두 허용합니다
+	Allow func(host string) bool
+	// SNI 가 없는 요청에 사용할 호스트
+	DefaultHost string
+
+	mu     sync.Mutex
+	ca     *x509.Certificate
+	caPriv interface{}
+	leaves map[string]*tls.Certificate
+}
+
+// NewIssuer 루트 인증서와 키로 발급자를 만듭니다
+func NewIssuer(ca *x509.Certificate, caPriv interface{}) (*Issuer, error) {
+	if !IsCA(ca) {
+		return nil, ErrNotCA
+	}
+
+	return &Issuer{
+		Validity: DefaultLeafValidity,
+		ca:       ca,
+		caPriv:   caPriv,
+		leaves:   map[string]*tls.Certificate{},
+	}, nil
+}
+
+// CA 루트 인증서를 반환합니다
+func (i *Issuer) CA() *x509.Certificate {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.ca
+}
+
+// SetCA 루트 인증서를 바꿉니다
+// 이전 루트 인증서로 발급한 인증서는 모두 버리기 때문에 다음 연결부터 새 인증서가 사용됩니다
+func (i *Issuer) SetCA(ca *x509.Certificate, caPriv interface{}) error {
+	if !IsCA(ca) {
+		return ErrNotCA
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.ca = ca
+	i.caPriv = caPriv
+	i.leaves = map[string]*tls.Certificate{}
+
+	return nil
+}
+
+// GetCertificate tls.Config 에서 사용할 수 있도록 SNI 이름으로 인증서를 발급합니다
+func (i *Issuer) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := hello.ServerName
+	if host == "" {
+		host = i.DefaultHost
+	}
+
+	return i.Certificate(host)
+}
+
+// Certificate 호스트의 인증서를 반환합니다
+// 캐시된 인증서가 없거나 만료가 가까우면 새로 발급합니다
+func (i *Issuer) Certificate(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return nil, fmt.Errorf("인증서를 발급할 호스트가 없습니다")
+	}
+
+	if i.Allow != nil && !i.Allow(host) {
+		return nil, fmt.Errorf("%s 호스트의 인증서는 발급할 수 없습니다", host)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// 남은 기간이 절반 이상이면 캐시된 인증서 사용하기
+	if leaf, ok := i.leaves[host]; ok && time.Until(leaf.Leaf.NotAfter) > i.Validity/2 {
+		return leaf, nil
+	}
+
+	leaf, e := i.issue(host)
+	if e != nil {
+		return nil, fmt.Errorf("%s 호스트의 인증서를 발급할 수 없습니다: %s", host, e)
+	}
+
+	i.leaves[host] = leaf
+
+	return leaf, nil
+}
+
+func (i *Issuer) issue(host string) (*tls.Certificate, error) {
+	priv, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		return nil, e
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: i.ca.Subject.Organization,
+			CommonName:   host,
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(i.Validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	// 루트 인증서보다 오래 유효할 수 없음
+	if template.NotAfter.After(i.ca.NotAfter) {
+		template.NotAfter = i.ca.NotAfter
+	}
+
+	cert, _, e := sign(template, priv, &priv.PublicKey, i.ca, i.caPriv)
+	if e != nil {
+		return nil, e
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{cert.Raw, i.ca.Raw},
+		PrivateKey:  priv,
+		Leaf:        cert,
+	}, nil
+}
@@ -0,0 +1,344 @@
+package certificate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// 키 알고리즘
+const (
+	RSA     = "rsa"
+	ECDSA   = "ecdsa"
+	Ed25519 = "ed25519"
+)
+
+// ErrPasswordRequired 암호화된 키를 비밀번호 없이 불러올 때 반환됩니다
+var ErrPasswordRequired = errors.New("암호화된 개인 키입니다, 비밀번호가 필요합니다")
+
+// ErrWrongPassword 비밀번호가 틀렸거나 키가 손상됐을 때 반환됩니다
+var ErrWrongPassword = errors.New("비밀번호가 틀렸거나 개인 키가 손상됐습니다")
+
+// 암호화할 때 사용할 PBKDF2 반복 횟수
+var pbkdf2Iterations = 100000
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// encryptedPrivateKeyInfo RFC 5208 암호화된 PKCS#8 구조
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+// pbes2Params RFC 8018 PBES2 파라미터
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params RFC 8018 PBKDF2 파라미터
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// GenerateKey 개인 키를 생성합니다
+// bits 가 0 이면 알고리즘의 기본 크기를 사용합니다 (RSA 2048, ECDSA 256), Ed25519 는 크기를 무시합니다
+// 대부분의 브라우저가 Ed25519 인증서를 신뢰하지 않으므로 TLS 인증서에는 RSA 나 ECDSA 를 사용해야 합니다
+func GenerateKey(algorithm string, bits int) (interface{}, error) {
+	switch algorithm {
+	case RSA, "":
+		if bits == 0 {
+			bits = 2048
+		}
+
+		if bits < minRSABits {
+			return nil, fmt.Errorf("RSA 키는 %d비트 이상이어야 합니다", minRSABits)
+		}
+
+		return rsa.GenerateKey(rand.Reader, bits)
+	case ECDSA:
+		var curve elliptic.Curve
+
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%d비트 ECDSA 키는 지원하지 않습니다 (256, 384, 521)", bits)
+		}
+
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case Ed25519:
+		_, priv, e := ed25519.GenerateKey(rand.Reader)
+		return priv, e
+	default:
+		return nil, fmt.Errorf("%s 값은 지원하지 않는 키 알고리즘입니다 (rsa, ecdsa, ed25519)", algorithm)
+	}
+}
+
+// MarshalPrivateKey 개인 키를 PEM 블록으로 만듭니다
+// pkcs8 이 false 라면 RSA 는 PKCS#1, ECDSA 는 SEC 1 형식을 사용하고 Ed25519 는 항상 PKCS#8 을 사용합니다
+// 비밀번호가 있다면 PKCS#8 PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) 로 암호화합니다
+func MarshalPrivateKey(priv interface{}, pkcs8 bool, password []byte) (*pem.Block, error) {
+	if pkcs8 || len(password) > 0 {
+		der, e := x509.MarshalPKCS8PrivateKey(priv)
+		if e != nil {
+			return nil, e
+		}
+
+		if len(password) == 0 {
+			return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+		}
+
+		encrypted, e := encryptPKCS8(der, password)
+		if e != nil {
+			return nil, e
+		}
+
+		return &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}, nil
+	}
+
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
+	case *ecdsa.PrivateKey:
+		b, e := x509.MarshalECPrivateKey(k)
+		if e != nil {
+			return nil, e
+		}
+
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}, nil
+	case ed25519.PrivateKey:
+		return MarshalPrivateKey(priv, true, nil)
+	default:
+		return nil, fmt.Errorf("Unsupported key: %T", k)
+	}
+}
+
+// ParsePrivateKey PEM 블록에서 개인 키를 불러옵니다
+// PKCS#1, SEC 1, PKCS#8, 암호화된 PKCS#8 과 OpenSSL 의 이전 암호화 형식 (Proc-Type: 4,ENCRYPTED) 을 지원합니다
+func ParsePrivateKey(block *pem.Block, password []byte) (interface{}, error) {
+	// 이전 OpenSSL 암호화 형식
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(password) == 0 {
+			return nil, ErrPasswordRequired
+		}
+
+		der, e := x509.DecryptPEMBlock(block, password)
+		if e != nil {
+			return nil, ErrWrongPassword
+		}
+
+		priv, e := parsePrivateKey(block.Type, der, nil)
+		if e != nil {
+			// 패딩이 우연히 맞았더라도 비밀번호가 틀리면 파싱에 실패함
+			return nil, ErrWrongPassword
+		}
+
+		return priv, nil
+	}
+
+	return parsePrivateKey(block.Type, block.Bytes, password)
+}
+
+func parsePrivateKey(blockType string, der []byte, password []byte) (interface{}, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(der)
+	case "ENCRYPTED PRIVATE KEY":
+		if len(password) == 0 {
+			return nil, ErrPasswordRequired
+		}
+
+		decrypted, e := decryptPKCS8(der, password)
+		if e != nil {
+			return nil, e
+		}
+
+		priv, e := x509.ParsePKCS8PrivateKey(decrypted)
+		if e != nil {
+			// 패딩이 우연히 맞았더라도 비밀번호가 틀리면 파싱에 실패함
+			return nil, ErrWrongPassword
+		}
+
+		return priv, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key: %s", blockType)
+	}
+}
+
+func encryptPKCS8(der []byte, password []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, e := rand.Read(salt); e != nil {
+		return nil, e
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, e := rand.Read(iv); e != nil {
+		return nil, e
+	}
+
+	key := pbkdf2.Key(password, salt, pbkdf2Iterations, 32, sha256.New)
+
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	// PKCS#7 패딩
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	data := make([]byte, len(der)+padding)
+	copy(data, der)
+	for i := len(der); i < len(data); i++ {
+		data[i] = byte(padding)
+	}
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, e := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pbkdf2Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	ivParams, e := asn1.Marshal(iv)
+	if e != nil {
+		return nil, e
+	}
+
+	params, e := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Data:      data,
+	})
+}
+
+func decryptPKCS8(der []byte, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, e := asn1.Unmarshal(der, &info); e != nil {
+		return nil, e
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("지원하지 않는 암호화 방식입니다: %s", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, e := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); e != nil {
+		return nil, e
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("지원하지 않는 키 유도 방식입니다: %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, e := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); e != nil {
+		return nil, e
+	}
+
+	var prf func() hash.Hash
+
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("지원하지 않는 PRF 입니다: %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLength int
+	var newCipher func([]byte) (cipher.Block, error)
+
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("지원하지 않는 암호화 방식입니다: %s", scheme)
+	}
+
+	var iv []byte
+	if _, e := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); e != nil {
+		return nil, e
+	}
+
+	key := pbkdf2.Key(password, kdf.Salt, kdf.Iterations, keyLength, prf)
+
+	block, e := newCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	if len(iv) != block.BlockSize() || len(info.Data) == 0 || len(info.Data)%block.BlockSize() != 0 {
+		return nil, ErrWrongPassword
+	}
+
+	data := make([]byte, len(info.Data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, info.Data)
+
+	// PKCS#7 패딩 확인하기
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, ErrWrongPassword
+	}
+
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, ErrWrongPassword
+		}
+	}
+
+	return data[:len(data)-padding], nil
+}
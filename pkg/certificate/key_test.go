@@ -0,0 +1,340 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	// 테스트에서는 암호화를 빠르게 하기
+	pbkdf2Iterations = 1000
+}
+
+// testKeys 지원하는 알고리즘마다 키를 하나씩 만듭니다
+func testKeys(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	keys := map[string]interface{}{}
+
+	for _, test := range []struct {
+		name      string
+		algorithm string
+		bits      int
+	}{
+		{"RSA 2048", RSA, 0},
+		{"ECDSA 256", ECDSA, 0},
+		{"ECDSA 384", ECDSA, 384},
+		{"ECDSA 521", ECDSA, 521},
+		{"Ed25519", Ed25519, 0},
+	} {
+		priv, e := GenerateKey(test.algorithm, test.bits)
+		if e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+
+		keys[test.name] = priv
+	}
+
+	return keys
+}
+
+// sameKey 두 개인 키가 같은지?
+func sameKey(t *testing.T, a, b interface{}) bool {
+	t.Helper()
+
+	x, e := x509.MarshalPKCS8PrivateKey(a)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	y, e := x509.MarshalPKCS8PrivateKey(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return bytes.Equal(x, y)
+}
+
+// legacyBlock OpenSSL 의 이전 암호화 형식 (Proc-Type: 4,ENCRYPTED) 으로 키를 암호화합니다
+func legacyBlock(t *testing.T, priv interface{}, password []byte) *pem.Block {
+	t.Helper()
+
+	block, e := MarshalPrivateKey(priv, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	encrypted, e := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, password, x509.PEMCipherAES256)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return encrypted
+}
+
+// roundTrip PEM 으로 인코딩한 뒤 다시 불러옵니다
+func roundTrip(block *pem.Block) *pem.Block {
+	decoded, _ := pem.Decode(pem.EncodeToMemory(block))
+	return decoded
+}
+
+func TestPrivateKeyFormats(t *testing.T) {
+	password := []byte("비밀번호")
+
+	tests := []struct {
+		format   string
+		password []byte
+		marshal  func(t *testing.T, priv interface{}) *pem.Block
+	}{
+		{"PKCS#1/SEC 1", nil, func(t *testing.T, priv interface{}) *pem.Block {
+			block, e := MarshalPrivateKey(priv, false, nil)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return block
+		}},
+		{"PKCS#8", nil, func(t *testing.T, priv interface{}) *pem.Block {
+			block, e := MarshalPrivateKey(priv, true, nil)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return block
+		}},
+		{"암호화된 PKCS#8", password, func(t *testing.T, priv interface{}) *pem.Block {
+			block, e := MarshalPrivateKey(priv, true, password)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return block
+		}},
+		{"이전 암호화 형식", password, func(t *testing.T, priv interface{}) *pem.Block {
+			return legacyBlock(t, priv, password)
+		}},
+	}
+
+	for name, priv := range testKeys(t) {
+		for _, test := range tests {
+			block := roundTrip(test.marshal(t, priv))
+
+			got, e := ParsePrivateKey(block, test.password)
+			if e != nil {
+				t.Errorf("%s %s: %v", name, test.format, e)
+				continue
+			}
+
+			if !sameKey(t, priv, got) {
+				t.Errorf("%s %s: 불러온 키가 다릅니다", name, test.format)
+			}
+
+			if test.password == nil {
+				continue
+			}
+
+			if _, e := ParsePrivateKey(block, nil); e != ErrPasswordRequired {
+				t.Errorf("%s %s: 비밀번호 없이 %v, 기대값 %v", name, test.format, e, ErrPasswordRequired)
+			}
+
+			if _, e := ParsePrivateKey(block, []byte("틀린 비밀번호")); e != ErrWrongPassword {
+				t.Errorf("%s %s: 틀린 비밀번호로 %v, 기대값 %v", name, test.format, e, ErrWrongPassword)
+			}
+		}
+	}
+}
+
+func TestPrivateKeyFormatNames(t *testing.T) {
+	keys := testKeys(t)
+
+	tests := []struct {
+		name   string
+		pkcs8  bool
+		secret bool
+		want   string
+	}{
+		{"RSA 2048", false, false, "RSA PRIVATE KEY"},
+		{"ECDSA 256", false, false, "EC PRIVATE KEY"},
+		{"Ed25519", false, false, "PRIVATE KEY"},
+		{"RSA 2048", true, false, "PRIVATE KEY"},
+		{"ECDSA 256", true, false, "PRIVATE KEY"},
+		{"RSA 2048", false, true, "ENCRYPTED PRIVATE KEY"},
+		{"Ed25519", false, true, "ENCRYPTED PRIVATE KEY"},
+	}
+
+	for _, test := range tests {
+		var password []byte
+		if test.secret {
+			password = []byte("secret")
+		}
+
+		block, e := MarshalPrivateKey(keys[test.name], test.pkcs8, password)
+		if e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+
+		if block.Type != test.want {
+			t.Errorf("%s pkcs8=%v 암호화=%v: %s, 기대값 %s", test.name, test.pkcs8, test.secret, block.Type, test.want)
+		}
+	}
+}
+
+func TestPrivateKeyCorrupted(t *testing.T) {
+	password := []byte("secret")
+
+	priv, e := GenerateKey(ECDSA, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	encrypted, e := MarshalPrivateKey(priv, true, password)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	legacy := legacyBlock(t, priv, password)
+
+	plain, e := MarshalPrivateKey(priv, false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// corrupt i 번째 바이트를 바꿉니다, 음수라면 뒤에서부터 셉니다
+	corrupt := func(block *pem.Block, i int) *pem.Block {
+		b := append([]byte(nil), block.Bytes...)
+		if i < 0 {
+			i += len(b)
+		}
+		b[i] ^= 0xff
+		return &pem.Block{Type: block.Type, Headers: block.Headers, Bytes: b}
+	}
+
+	// truncate 뒤쪽 절반을 버립니다
+	truncate := func(block *pem.Block) *pem.Block {
+		return &pem.Block{Type: block.Type, Headers: block.Headers, Bytes: block.Bytes[:len(block.Bytes)/2]}
+	}
+
+	tests := []struct {
+		name     string
+		block    *pem.Block
+		password []byte
+		want     error
+	}{
+		{"손상된 암호화된 PKCS#8", corrupt(encrypted, -1), password, nil},
+		{"잘린 암호화된 PKCS#8", truncate(encrypted), password, nil},
+		{"손상된 이전 암호화 형식", corrupt(legacy, -1), password, ErrWrongPassword},
+		{"잘린 이전 암호화 형식", truncate(legacy), password, ErrWrongPassword},
+		{"손상된 SEC 1", corrupt(plain, 0), nil, nil},
+		{"잘린 SEC 1", truncate(plain), nil, nil},
+		{"알 수 없는 형식", &pem.Block{Type: "DSA PRIVATE KEY", Bytes: plain.Bytes}, nil, nil},
+	}
+
+	for _, test := range tests {
+		got, e := ParsePrivateKey(test.block, test.password)
+		if e == nil {
+			t.Errorf("%s: 오류 없이 %T 키를 불러왔습니다", test.name, got)
+			continue
+		}
+
+		if test.want != nil && e != test.want {
+			t.Errorf("%s: %v, 기대값 %v", test.name, e, test.want)
+		}
+	}
+}
+
+func TestGenerateKeyInvalid(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		bits      int
+	}{
+		{RSA, 1024},
+		{ECDSA, 224},
+		{"dsa", 0},
+	}
+
+	for _, test := range tests {
+		if _, e := GenerateKey(test.algorithm, test.bits); e == nil {
+			t.Errorf("%s %d: 오류가 없습니다", test.algorithm, test.bits)
+		}
+	}
+}
+
+func TestValidateEd25519(t *testing.T) {
+	priv, e := GenerateKey(Ed25519, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "nicotrans 테스트"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		PermittedDNSDomains:   []string{"nicovideo.jp"},
+	}
+
+	cert, priv, e := CreateWithKey(template, priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Ed25519 루트 인증서는 새로 만들어야 함
+	problems := Validate(cert, priv, []string{"nmsg.nicovideo.jp"})
+	if len(problems) != 1 || problems[0] != ErrEd25519 {
+		t.Errorf("%v, 기대값 [%v]", problems, ErrEd25519)
+	}
+}
+
+// TestOpenSSLKeys OpenSSL 로 만든 키를 불러올 수 있는지 확인합니다
+func TestOpenSSLKeys(t *testing.T) {
+	if _, e := exec.LookPath("openssl"); e != nil {
+		t.Skip("openssl 이 없습니다")
+	}
+
+	dir := t.TempDir()
+	password := "secret"
+
+	tests := []struct {
+		name     string
+		args     []string
+		password []byte
+	}{
+		{"RSA PKCS#8", []string{"genpkey", "-algorithm", "RSA", "-pkeyopt", "rsa_keygen_bits:2048"}, nil},
+		{"ECDSA PKCS#8", []string{"genpkey", "-algorithm", "EC", "-pkeyopt", "ec_paramgen_curve:P-384"}, nil},
+		{"Ed25519 PKCS#8", []string{"genpkey", "-algorithm", "ED25519"}, nil},
+		{"암호화된 RSA PKCS#8", []string{"genpkey", "-algorithm", "RSA", "-aes-256-cbc", "-pass", "pass:" + password}, []byte(password)},
+		{"암호화된 ECDSA PKCS#8", []string{"genpkey", "-algorithm", "EC", "-pkeyopt", "ec_paramgen_curve:P-256", "-aes-128-cbc", "-pass", "pass:" + password}, []byte(password)},
+		{"SEC 1", []string{"ecparam", "-name", "prime256v1", "-genkey", "-noout"}, nil},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, string(rune('a'+i))+".pem")
+
+		if out, e := exec.Command("openssl", append(test.args, "-out", path)...).CombinedOutput(); e != nil {
+			t.Logf("%s: openssl 이 지원하지 않습니다: %s", test.name, out)
+			continue
+		}
+
+		data, e := ioutil.ReadFile(path)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			t.Errorf("%s: PEM 블록이 없습니다", test.name)
+			continue
+		}
+
+		if _, e := ParsePrivateKey(block, test.password); e != nil {
+			t.Errorf("%s (%s): %v", test.name, block.Type, e)
+		}
+	}
+}
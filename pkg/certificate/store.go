@@ -0,0 +1,70 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Store 루트 인증서를 설치할 수 있는 저장소
+type Store interface {
+	// Name 저장소 이름
+	Name() string
+	// Contains 인증서가 이미 설치되어있는지?
+	Contains(cert *x509.Certificate) (bool, error)
+	// Install 인증서를 설치합니다
+	Install(cert *x509.Certificate) error
+	// Uninstall 인증서를 제거합니다
+	Uninstall(cert *x509.Certificate) error
+	// Plan 인증서를 설치할 때 바뀌는 내용을 설명합니다
+	Plan(cert *x509.Certificate) []string
+}
+
+// InstallAsRootCA 인증서를 신뢰할 수 있는 루트 인증 기관으로 설치합니다
+// 사용할 수 있는 모든 저장소에 설치하며, 새로 설치한 저장소 목록을 반환합니다
+func InstallAsRootCA(cert *x509.Certificate) ([]Store, error) {
+	stores := Stores()
+	if len(stores) == 0 {
+		return nil, errors.New("인증서를 설치할 수 있는 저장소가 없습니다")
+	}
+
+	var installed []Store
+	var errs []string
+
+	for _, store := range stores {
+		ok, e := store.Contains(cert)
+		if e != nil {
+			errs = append(errs, fmt.Sprintf("%s (%s)", store.Name(), e))
+			continue
+		}
+
+		if ok {
+			continue
+		}
+
+		if e := store.Install(cert); e != nil {
+			errs = append(errs, fmt.Sprintf("%s (%s)", store.Name(), e))
+			continue
+		}
+
+		installed = append(installed, store)
+	}
+
+	if len(errs) > 0 {
+		return installed, fmt.Errorf("일부 저장소에 인증서를 설치하지 못했습니다: %s", strings.Join(errs, ", "))
+	}
+
+	return installed, nil
+}
+
+// FindStore 이름으로 저장소를 찾습니다
+func FindStore(name string) Store {
+	for _, store := range Stores() {
+		if store.Name() == name {
+			return store
+		}
+	}
+
+	return nil
+}
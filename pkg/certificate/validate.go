@@ -0,0 +1,148 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 인증서 검사 오류
+var (
+	ErrExpired     = errors.New("인증서가 만료됐습니다")
+	ErrNotYetValid = errors.New("인증서가 아직 유효하지 않습니다")
+	ErrKeyMismatch = errors.New("인증서와 개인 키가 맞지 않습니다")
+	ErrNotCA       = errors.New("다른 인증서를 서명할 수 없는 인증서입니다")
+	// 키가 유출되면 모든 사이트의 인증서를 만들 수 있으므로 새로 만들어야 합니다
+	ErrUnconstrained = errors.New("이름 제약 조건이 없어 모든 사이트의 인증서를 발급할 수 있는 인증서입니다")
+	ErrEd25519       = errors.New("대부분의 브라우저가 신뢰하지 않는 Ed25519 키를 사용하는 인증서입니다")
+)
+
+// 안전하다고 볼 수 있는 최소 키 크기
+var (
+	minRSABits   = 2048
+	minECDSABits = 256
+)
+
+// Validate 루트 인증서와 키를 검사하고 발견한 문제를 모두 반환합니다
+// hosts 는 이 인증서로 발급할 수 있어야 하는 호스트나 도메인 목록입니다
+func Validate(cert *x509.Certificate, priv interface{}, hosts []string) []error {
+	var problems []error
+
+	now := time.Now()
+	if now.After(cert.NotAfter) {
+		problems = append(problems, ErrExpired)
+	} else if now.Before(cert.NotBefore) {
+		problems = append(problems, ErrNotYetValid)
+	}
+
+	if !IsCA(cert) {
+		problems = append(problems, ErrNotCA)
+	}
+
+	if e := matchKey(cert, priv); e != nil {
+		problems = append(problems, e)
+	}
+
+	if e := checkKeyStrength(cert); e != nil {
+		problems = append(problems, e)
+	}
+
+	if len(cert.PermittedDNSDomains) == 0 {
+		problems = append(problems, ErrUnconstrained)
+	}
+
+	for _, host := range hosts {
+		if !permitsHost(cert, host) {
+			problems = append(problems, fmt.Errorf("%s 호스트의 인증서를 발급할 수 없는 인증서입니다", host))
+		}
+	}
+
+	return problems
+}
+
+// ExpiresWithin 인증서가 주어진 기간 안에 만료되는지?
+func ExpiresWithin(cert *x509.Certificate, d time.Duration) bool {
+	return time.Until(cert.NotAfter) < d
+}
+
+// matchKey 개인 키가 인증서의 공개 키와 짝이 맞는지 확인합니다
+func matchKey(cert *x509.Certificate, priv interface{}) error {
+	pub, e := exportPublicKey(priv)
+	if e != nil {
+		return e
+	}
+
+	a, e := x509.MarshalPKIXPublicKey(pub)
+	if e != nil {
+		return e
+	}
+
+	b, e := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if e != nil {
+		return e
+	}
+
+	if !bytes.Equal(a, b) {
+		return ErrKeyMismatch
+	}
+
+	return nil
+}
+
+func checkKeyStrength(cert *x509.Certificate) error {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA 키가 너무 짧습니다: %d비트", k.N.BitLen())
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().BitSize < minECDSABits {
+			return fmt.Errorf("ECDSA 키가 너무 짧습니다: %d비트", k.Curve.Params().BitSize)
+		}
+	case ed25519.PublicKey:
+		return ErrEd25519
+	default:
+		return fmt.Errorf("지원하지 않는 키입니다: %T", k)
+	}
+
+	switch cert.SignatureAlgorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return fmt.Errorf("안전하지 않은 서명 알고리즘입니다: %s", cert.SignatureAlgorithm)
+	}
+
+	return nil
+}
+
+// permitsHost 이름 제약 조건이 호스트를 허용하는지?
+func permitsHost(cert *x509.Certificate, host string) bool {
+	for _, domain := range cert.ExcludedDNSDomains {
+		if matchDomain(host, domain) {
+			return false
+		}
+	}
+
+	if len(cert.PermittedDNSDomains) == 0 {
+		return true
+	}
+
+	for _, domain := range cert.PermittedDNSDomains {
+		if matchDomain(host, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchDomain(host, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,51 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomic 같은 폴더의 임시 파일에 쓴 뒤 이름을 바꿔 파일을 한 번에 교체합니다
+// 쓰다가 멈추거나 전원이 꺼져도 이전 파일이나 새 파일 중 하나가 온전히 남습니다
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	file, e := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if e != nil {
+		return e
+	}
+
+	// 실패하면 임시 파일 지우기
+	tmpPath := file.Name()
+	ok := false
+
+	defer func() {
+		if !ok {
+			file.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if e := file.Chmod(perm); e != nil {
+		return e
+	}
+
+	if _, e := file.Write(data); e != nil {
+		return e
+	}
+
+	if e := file.Sync(); e != nil {
+		return e
+	}
+
+	if e := file.Close(); e != nil {
+		return e
+	}
+
+	if e := os.Rename(tmpPath, path); e != nil {
+		return e
+	}
+
+	ok = true
+
+	return nil
+}
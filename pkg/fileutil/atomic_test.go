@@ -0,0 +1,67 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		data string
+		perm os.FileMode
+	}{
+		{"cache.json", "[]", 0600},
+		{"cache.json", "[{\"text\":\"こんにちは\"}]", 0600},
+		{"nicotrans.crt", "", 0644},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+
+		if e := WriteAtomic(path, []byte(test.data), test.perm); e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+
+		data, e := ioutil.ReadFile(path)
+		if e != nil || string(data) != test.data {
+			t.Errorf("%s: %q %v, 기대값 %q", test.name, data, e, test.data)
+		}
+
+		// 윈도우는 유닉스 권한을 지원하지 않음
+		if runtime.GOOS == "windows" {
+			continue
+		}
+
+		if info, e := os.Stat(path); e != nil {
+			t.Error(e)
+		} else if info.Mode().Perm() != test.perm {
+			t.Errorf("%s: 권한 %v, 기대값 %v", test.name, info.Mode().Perm(), test.perm)
+		}
+	}
+
+	// 실패해도 이전 파일과 임시 파일이 남지 않아야 함
+	if e := WriteAtomic(filepath.Join(dir, "없는 폴더", "cache.json"), nil, 0600); e == nil {
+		t.Error("없는 폴더에 썼습니다")
+	}
+
+	if e := WriteAtomic(dir, nil, 0600); e == nil {
+		t.Error("폴더를 파일로 덮어썼습니다")
+	}
+
+	files, e := ioutil.ReadDir(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(files) != 2 {
+		for _, file := range files {
+			t.Errorf("남은 파일: %s", file.Name())
+		}
+	}
+}
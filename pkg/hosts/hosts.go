@@ -0,0 +1,268 @@
+package hosts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// 니코트랜스가 추가한 항목을 감싸는 주석
+const (
+	BeginMarker = "# BEGIN nicotrans"
+	EndMarker   = "# END nicotrans"
+)
+
+// Entry 호스트 파일의 아이피와 호스트 한 쌍
+type Entry struct {
+	IP   string
+	Host string
+}
+
+// Process 블록을 추가한 프로세스
+// 프로세스 아이디는 다시 사용되므로 시작 시점도 함께 기록해 같은 프로세스인지 구분합니다
+type Process struct {
+	PID   int
+	Start string
+}
+
+// DefaultPath 운영체제의 호스트 파일 경로를 반환합니다
+func DefaultPath() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+
+	return "/etc/hosts"
+}
+
+// File 불러온 호스트 파일
+// 니코트랜스가 추가한 항목은 BeginMarker, EndMarker 주석 사이의 블록에만 두고 나머지 줄은 그대로 보존합니다
+type File struct {
+	Path string
+
+	lines []string
+	crlf  bool
+	mode  os.FileMode
+}
+
+// Load 호스트 파일을 불러옵니다, 파일이 없다면 빈 파일로 취급합니다
+func Load(path string) (*File, error) {
+	f := &File{Path: path, mode: 0644}
+
+	data, e := ioutil.ReadFile(path)
+	if os.IsNotExist(e) {
+		return f, nil
+	} else if e != nil {
+		return nil, e
+	}
+
+	if info, e := os.Stat(path); e == nil {
+		f.mode = info.Mode().Perm()
+	}
+
+	f.crlf = bytes.Contains(data, []byte("\r\n"))
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+
+	return f, nil
+}
+
+// Has 아이피와 호스트 항목이 파일 어디든 존재하는지?
+func (f *File) Has(ip, host string) bool {
+	for _, line := range f.lines {
+		lineIP, hosts := parseLine(line)
+		if lineIP != ip {
+			continue
+		}
+
+		for _, h := range hosts {
+			if strings.EqualFold(h, host) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Block 니코트랜스 블록 안의 항목을 반환합니다
+func (f *File) Block() []Entry {
+	begin, end := f.block()
+	if begin < 0 {
+		return nil
+	}
+
+	var entries []Entry
+	for _, line := range f.lines[begin+1 : end] {
+		ip, hosts := parseLine(line)
+		for _, host := range hosts {
+			entries = append(entries, Entry{IP: ip, Host: host})
+		}
+	}
+
+	return entries
+}
+
+// Owner 블록을 추가한 프로세스를 반환합니다, 블록이 없거나 기록되지 않았다면 빈 값을 반환합니다
+func (f *File) Owner() Process {
+	var owner Process
+
+	begin, _ := f.block()
+	if begin < 0 {
+		return owner
+	}
+
+	for _, field := range strings.Fields(strings.TrimPrefix(strings.TrimSpace(f.lines[begin]), BeginMarker)) {
+		switch {
+		case strings.HasPrefix(field, "pid="):
+			owner.PID, _ = strconv.Atoi(strings.TrimPrefix(field, "pid="))
+		case strings.HasPrefix(field, "start="):
+			owner.Start = strings.TrimPrefix(field, "start=")
+		}
+	}
+
+	if owner.PID == 0 {
+		return Process{}
+	}
+
+	return owner
+}
+
+// SetBlock 니코트랜스 블록을 주어진 항목으로 바꿉니다, 항목이 없다면 블록을 지웁니다
+// owner 가 비어있지 않다면 시작 주석에 프로세스를 기록해 비정상 종료로 남은 블록을 찾을 수 있게 합니다
+func (f *File) SetBlock(entries []Entry, owner Process) {
+	var block []string
+	if len(entries) > 0 {
+		marker := BeginMarker
+		if owner.PID != 0 {
+			marker += " pid=" + strconv.Itoa(owner.PID)
+
+			if owner.Start != "" {
+				marker += " start=" + owner.Start
+			}
+		}
+
+		block = append(block, marker)
+		for _, entry := range entries {
+			block = append(block, entry.IP+"\t"+entry.Host)
+		}
+		block = append(block, EndMarker)
+	}
+
+	begin, end := f.block()
+	if begin < 0 {
+		if len(block) > 0 {
+			f.lines = append(f.lines, block...)
+		}
+
+		return
+	}
+
+	lines := append([]string{}, f.lines[:begin]...)
+	lines = append(lines, block...)
+	if end < len(f.lines) {
+		lines = append(lines, f.lines[end+1:]...)
+	}
+
+	f.lines = lines
+}
+
+// Remove 파일 어디에 있든 아이피와 호스트 항목을 지웁니다
+// 블록을 사용하기 전 버전이 추가한 항목을 정리할 때 사용합니다
+func (f *File) Remove(ip, host string) {
+	var lines []string
+
+	for _, line := range f.lines {
+		lineIP, hosts := parseLine(line)
+		if lineIP != ip {
+			lines = append(lines, line)
+			continue
+		}
+
+		var rest []string
+		for _, h := range hosts {
+			if !strings.EqualFold(h, host) {
+				rest = append(rest, h)
+			}
+		}
+
+		// 다른 호스트가 남아있다면 그 호스트와 줄 끝 주석만 남기기
+		if len(rest) == len(hosts) {
+			lines = append(lines, line)
+		} else if len(rest) > 0 {
+			rewritten := ip + "\t" + strings.Join(rest, " ")
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				rewritten += " " + line[i:]
+			}
+
+			lines = append(lines, rewritten)
+		}
+	}
+
+	f.lines = lines
+}
+
+// Save 호스트 파일을 저장합니다
+// 컨테이너처럼 호스트 파일이 마운트된 환경에서는 이름을 바꿀 수 없기 때문에 파일을 직접 덮어씁니다
+func (f *File) Save() error {
+	newline := "\n"
+	if f.crlf {
+		newline = "\r\n"
+	}
+
+	var b strings.Builder
+	for _, line := range f.lines {
+		b.WriteString(line)
+		b.WriteString(newline)
+	}
+
+	return ioutil.WriteFile(f.Path, []byte(b.String()), f.mode)
+}
+
+// block 블록의 시작과 끝 줄 번호를 반환합니다, 블록이 없다면 -1 을 반환합니다
+func (f *File) block() (int, int) {
+	begin := -1
+
+	for i, line := range f.lines {
+		line = strings.TrimSpace(line)
+
+		if begin < 0 && strings.HasPrefix(line, BeginMarker) {
+			begin = i
+		} else if begin >= 0 && strings.HasPrefix(line, EndMarker) {
+			return begin, i
+		}
+	}
+
+	// 끝 주석이 없다면 파일 끝까지를 블록으로 취급
+	if begin >= 0 {
+		return begin, len(f.lines)
+	}
+
+	return -1, -1
+}
+
+// parseLine 한 줄을 아이피와 호스트 목록으로 나눕니다, 주석이나 빈 줄은 빈 아이피를 반환합니다
+func parseLine(line string) (string, []string) {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", nil
+	}
+
+	return fields[0], fields[1:]
+}
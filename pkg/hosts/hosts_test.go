@@ -0,0 +1,243 @@
+package hosts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeHosts 임시 디렉터리에 호스트 파일을 만듭니다
+func writeHosts(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hosts")
+	if e := ioutil.WriteFile(path, []byte(content), 0640); e != nil {
+		t.Fatal(e)
+	}
+
+	return path
+}
+
+// readHosts 호스트 파일 내용을 읽습니다
+func readHosts(t *testing.T, path string) string {
+	t.Helper()
+
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return string(data)
+}
+
+// load 호스트 파일을 불러오고 실패하면 테스트를 멈춥니다
+func load(t *testing.T, path string) *File {
+	t.Helper()
+
+	f, e := Load(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return f
+}
+
+var entries = []Entry{
+	{IP: "127.0.0.2", Host: "nmsg.nicovideo.jp"},
+	{IP: "127.0.0.2", Host: "nmsg.live.nicovideo.jp"},
+}
+
+func TestLoad(t *testing.T) {
+	path := writeHosts(t, "127.0.0.1\tlocalhost\n# 127.0.0.2 commented.test\n::1 localhost ip6-localhost # loopback\n")
+	f := load(t, path)
+
+	tests := []struct {
+		ip   string
+		host string
+		want bool
+	}{
+		{"127.0.0.1", "localhost", true},
+		{"127.0.0.1", "LOCALHOST", true},
+		{"::1", "ip6-localhost", true},
+		{"127.0.0.2", "commented.test", false},
+		{"127.0.0.2", "localhost", false},
+		{"::1", "loopback", false},
+	}
+
+	for _, test := range tests {
+		if got := f.Has(test.ip, test.host); got != test.want {
+			t.Errorf("%s %s: %v, 기대값 %v", test.ip, test.host, got, test.want)
+		}
+	}
+
+	if f.Block() != nil || f.Owner() != (Process{}) {
+		t.Errorf("블록 %v, 프로세스 %+v", f.Block(), f.Owner())
+	}
+
+	// 파일이 없다면 빈 파일로 취급해야 함
+	f, e := Load(filepath.Join(t.TempDir(), "hosts"))
+	if e != nil || f.Has("127.0.0.1", "localhost") || f.Block() != nil {
+		t.Errorf("없는 파일: %v", e)
+	}
+}
+
+func TestSetBlock(t *testing.T) {
+	original := "127.0.0.1\tlocalhost\n# 사용자 주석\n"
+	path := writeHosts(t, original)
+
+	f := load(t, path)
+	owner := Process{PID: 1234, Start: "3f2a9c1e.5678"}
+	f.SetBlock(entries, owner)
+
+	if e := f.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	want := original + "# BEGIN nicotrans pid=1234 start=3f2a9c1e.5678\n127.0.0.2\tnmsg.nicovideo.jp\n127.0.0.2\tnmsg.live.nicovideo.jp\n# END nicotrans\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("%q, 기대값 %q", got, want)
+	}
+
+	// 권한을 유지해야 함
+	if info, e := os.Stat(path); e != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("권한 %v (%v), 기대값 0640", info.Mode().Perm(), e)
+	}
+
+	f = load(t, path)
+	if got := f.Block(); len(got) != 2 || got[0] != entries[0] || got[1] != entries[1] {
+		t.Errorf("블록 %v, 기대값 %v", got, entries)
+	}
+
+	if f.Owner() != owner {
+		t.Errorf("프로세스 %+v, 기대값 %+v", f.Owner(), owner)
+	}
+
+	// 블록을 지우면 원래 내용만 남아야 함
+	f.SetBlock(nil, Process{})
+
+	if e := f.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	if got := readHosts(t, path); got != original {
+		t.Errorf("%q, 기대값 %q", got, original)
+	}
+}
+
+func TestSetBlockIdempotent(t *testing.T) {
+	original := "127.0.0.1\tlocalhost\n\n# BEGIN nicotrans\n127.0.0.3\told.test\n# END nicotrans\n10.0.0.1\tnas.lan\n"
+	path := writeHosts(t, original)
+
+	// 여러 번 적용해도 블록은 하나만 있어야 하고 블록 밖의 줄은 그대로 남아야 함
+	for i := 0; i < 3; i++ {
+		f := load(t, path)
+		f.SetBlock(entries, Process{})
+
+		if e := f.Save(); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	want := "127.0.0.1\tlocalhost\n\n# BEGIN nicotrans\n127.0.0.2\tnmsg.nicovideo.jp\n127.0.0.2\tnmsg.live.nicovideo.jp\n# END nicotrans\n10.0.0.1\tnas.lan\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("%q, 기대값 %q", got, want)
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	original := "127.0.0.1\tlocalhost\r\n# Windows 호스트 파일\r\n"
+	path := writeHosts(t, original)
+
+	f := load(t, path)
+	f.SetBlock(entries[:1], Process{})
+
+	if e := f.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	want := original + "# BEGIN nicotrans\r\n127.0.0.2\tnmsg.nicovideo.jp\r\n# END nicotrans\r\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("%q, 기대값 %q", got, want)
+	}
+
+	f = load(t, path)
+	f.SetBlock(nil, Process{})
+
+	if e := f.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	if got := readHosts(t, path); got != original {
+		t.Errorf("%q, 기대값 %q", got, original)
+	}
+}
+
+func TestMissingEndMarker(t *testing.T) {
+	// 블록을 쓰던 중에 멈춰 끝 주석이 없다면 파일 끝까지를 블록으로 취급해야 함
+	path := writeHosts(t, "127.0.0.1\tlocalhost\n# BEGIN nicotrans pid=99\n127.0.0.2\tnmsg.nicovideo.jp\n127.0.0.2\tnmsg.live")
+
+	f := load(t, path)
+	if got := f.Block(); len(got) != 2 || got[1].Host != "nmsg.live" {
+		t.Errorf("블록 %v", got)
+	}
+
+	if f.Owner() != (Process{PID: 99}) {
+		t.Errorf("프로세스 %+v, 기대값 pid 99", f.Owner())
+	}
+
+	f.SetBlock(entries, Process{})
+
+	if e := f.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	want := "127.0.0.1\tlocalhost\n# BEGIN nicotrans\n127.0.0.2\tnmsg.nicovideo.jp\n127.0.0.2\tnmsg.live.nicovideo.jp\n# END nicotrans\n"
+	if got := readHosts(t, path); got != want {
+		t.Errorf("%q, 기대값 %q", got, want)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		want     string
+	}{
+		{
+			"한 줄 전체",
+			"127.0.0.1\tlocalhost\n127.0.0.1\tnmsg.nicovideo.jp\n",
+			"127.0.0.1\tlocalhost\n",
+		},
+		{
+			"다른 호스트와 주석은 남기기",
+			"127.0.0.1 NMSG.nicovideo.jp other.test # 이전 버전\n",
+			"127.0.0.1\tother.test # 이전 버전\n",
+		},
+		{
+			"다른 아이피는 그대로",
+			"10.0.0.1\tnmsg.nicovideo.jp\n",
+			"10.0.0.1\tnmsg.nicovideo.jp\n",
+		},
+		{
+			"주석 처리된 항목은 그대로",
+			"# 127.0.0.1 nmsg.nicovideo.jp\n",
+			"# 127.0.0.1 nmsg.nicovideo.jp\n",
+		},
+	}
+
+	for _, test := range tests {
+		path := writeHosts(t, test.original)
+
+		f := load(t, path)
+		f.Remove("127.0.0.1", "nmsg.nicovideo.jp")
+
+		if e := f.Save(); e != nil {
+			t.Fatal(e)
+		}
+
+		if got := readHosts(t, path); got != test.want {
+			t.Errorf("%s: %q, 기대값 %q", test.name, got, test.want)
+		}
+	}
+}
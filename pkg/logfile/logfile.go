@@ -0,0 +1,127 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File 크기가 커지면 이전 파일을 path.1, path.2 ... 로 옮기고 새 파일에 이어 쓰는 기록 파일
+type File struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open 기록 파일을 열거나 만듭니다
+// maxSize 가 0 이하라면 교체하지 않고, maxBackups 는 보관할 이전 파일 수입니다
+func Open(path string, maxSize int64, maxBackups int) (*File, error) {
+	f := &File{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	if e := f.open(); e != nil {
+		return nil, e
+	}
+
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, e := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if e != nil {
+		return e
+	}
+
+	info, e := file.Stat()
+	if e != nil {
+		file.Close()
+		return e
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// Write 기록을 씁니다, 쓰면 maxSize 를 넘는다면 먼저 파일을 교체합니다
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if e := f.rotate(); e != nil {
+			return 0, e
+		}
+	}
+
+	n, e := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, e
+}
+
+// Rotate 지금 파일을 이전 파일로 옮기고 새 파일을 엽니다
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if e := f.file.Close(); e != nil {
+		return e
+	}
+
+	f.file = nil
+
+	if f.maxBackups > 0 {
+		// 가장 오래된 파일부터 한 칸씩 밀기
+		os.Remove(f.backup(f.maxBackups))
+
+		for i := f.maxBackups - 1; i > 0; i-- {
+			if e := os.Rename(f.backup(i), f.backup(i+1)); e != nil && !os.IsNotExist(e) {
+				return e
+			}
+		}
+
+		if e := os.Rename(f.path, f.backup(1)); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+	} else if e := os.Remove(f.path); e != nil && !os.IsNotExist(e) {
+		return e
+	}
+
+	return f.open()
+}
+
+func (f *File) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close 기록 파일을 닫습니다
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	e := f.file.Close()
+	f.file = nil
+
+	return e
+}
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 히스토그램 기본 구간 (초)
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default 기본 레지스트리
+var Default = NewRegistry()
+
+// collector 프로메테우스 텍스트 형식으로 값을 쓸 수 있는 지표
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry 지표 목록
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry 빈 레지스트리를 만듭니다
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic(fmt.Sprintf("%s 지표가 이미 존재합니다", c.name()))
+		}
+	}
+
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo 모든 지표를 프로메테우스 텍스트 형식으로 씁니다
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].name() < collectors[j].name()
+	})
+
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+
+	e := buffered.Flush()
+
+	return counter.n, e
+}
+
+// Handler 지표를 응답하는 핸들러를 만듭니다
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+
+		r.WriteTo(w)
+	})
+}
+
+// desc 지표 이름, 설명, 레이블 이름
+type desc struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+func (d *desc) name() string {
+	return d.Name
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.Name, escapeHelp(d.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.Name, d.Type)
+}
+
+// labelKey 레이블 값 목록을 맵 키로 바꿉니다
+func (d *desc) labelKey(values []string) string {
+	if len(values) != len(d.Labels) {
+		panic(fmt.Sprintf("%s 지표의 레이블은 %d개여야 합니다: %v", d.Name, len(d.Labels), values))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels 레이블을 {a="b",c="d"} 형식으로 만듭니다, extra 는 마지막에 붙입니다
+func formatLabels(names []string, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(extra[i+1]))
+		b.WriteByte('"')
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// sortedKeys 레이블 키를 정렬해서 반환합니다
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, e := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, e
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"sync"
+)
+
+// CounterVec 레이블별로 늘어나기만 하는 값
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// NewCounterVec 카운터를 만들어 레지스트리에 등록합니다
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{Name: name, Help: help, Type: "counter", Labels: labels},
+		values: map[string]float64{},
+		labels: map[string][]string{},
+	}
+
+	r.register(c)
+
+	return c
+}
+
+// Add 레이블 값에 해당하는 카운터를 v 만큼 늘립니다, 음수는 무시합니다
+func (c *CounterVec) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+
+	key := c.labelKey(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.labels[key]; !ok {
+		c.labels[key] = append([]string{}, values...)
+	}
+
+	c.values[key] += v
+}
+
+// Inc 레이블 값에 해당하는 카운터를 1 늘립니다
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 레이블이 없는 카운터는 한 번도 늘리지 않았어도 0 을 쓰기
+	if len(c.Labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.Name)
+		return
+	}
+
+	for _, key := range sortedKeys(c.labels) {
+		fmt.Fprintf(w, "%s%s %s\n", c.Name, formatLabels(c.Labels, c.labels[key]), formatValue(c.values[key]))
+	}
+}
+
+// HistogramVec 레이블별로 값의 분포를 구간으로 나눠 기록합니다
+type HistogramVec struct {
+	desc
+
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+	labels map[string][]string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec 히스토그램을 만들어 레지스트리에 등록합니다, buckets 가 비어있다면 DefaultBuckets 를 사용합니다
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	h := &HistogramVec{
+		desc:    desc{Name: name, Help: help, Type: "histogram", Labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogram{},
+		labels:  map[string][]string{},
+	}
+
+	r.register(h)
+
+	return h
+}
+
+// Observe 레이블 값에 해당하는 히스토그램에 값을 기록합니다
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.labelKey(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+		h.labels[key] = append([]string{}, values...)
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.labels) {
+		s := h.series[key]
+		values := h.labels[key]
+
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.Name, formatLabels(h.Labels, values, "le", formatValue(upper)), s.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.Name, formatLabels(h.Labels, values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.Name, formatLabels(h.Labels, values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.Name, formatLabels(h.Labels, values), s.count)
+	}
+}
+
+// funcMetric 읽을 때마다 함수로 값을 가져오는 지표
+// 다른 패키지가 이미 세고 있는 값을 그대로 내보낼 때 사용합니다
+type funcMetric struct {
+	desc
+
+	value func() float64
+}
+
+// NewCounterFunc 함수로 값을 가져오는 카운터를 등록합니다
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{desc: desc{Name: name, Help: help, Type: "counter"}, value: value})
+}
+
+// NewGaugeFunc 함수로 값을 가져오는 게이지를 등록합니다
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{desc: desc{Name: name, Help: help, Type: "gauge"}, value: value})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", f.Name, formatValue(f.value()))
+}
@@ -2,10 +2,14 @@ package nico
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"regexp"
+
+	"github.com/hype5/nicotrans-go/pkg/trace"
 )
 
 // PayloadPing ?
@@ -84,25 +88,53 @@ type Message struct {
 	Error   error
 }
 
+// Thread 메세지에 들어있는 첫 번째 스레드 번호를 반환합니다
+func (m Message) Thread() string {
+	for _, payload := range m.Payload {
+		if payload.Thread != nil {
+			return payload.Thread.Thread
+		}
+	}
+
+	return ""
+}
+
 var chunkPattern = regexp.MustCompile(`(?m)^§\n([^§]+)`)
 
-// Fetch 메세지를 불러옵니다
-func Fetch(data io.Reader) <-chan Message {
+// Fetch 메세지를 불러옵니다, ctx 가 취소되면 요청을 멈춥니다
+func Fetch(ctx context.Context, data io.Reader) <-chan Message {
 	resolve := make(chan Message)
 
 	go func() {
 		var result Message
 
+		_, span := trace.Start(ctx, "nico.Fetch")
+		span.Kind = trace.KindClient
+
 		defer func() {
+			span.SetAttribute("nico.chats", len(result.Chats))
+			span.SetError(result.Error)
+			span.Finish()
+
 			resolve <- result
 		}()
 
-		res, e := Net.Post("https://nmsg.nicovideo.jp/api.json/", "text/plain", data)
+		req, e := http.NewRequest(http.MethodPost, "https://nmsg.nicovideo.jp/api.json/", data)
 		if e != nil {
 			result.Error = e
 			return
 		}
 
+		req.Header.Set("Content-Type", "text/plain")
+
+		res, e := Net.Do(req.WithContext(ctx))
+		if e != nil {
+			result.Error = e
+			return
+		}
+
+		span.SetAttribute("http.status_code", res.StatusCode)
+
 		defer res.Body.Close()
 		body, e := ioutil.ReadAll(res.Body)
 		if e != nil {
@@ -110,6 +142,8 @@ func Fetch(data io.Reader) <-chan Message {
 			return
 		}
 
+		span.SetAttribute("http.response_content_length", len(body))
+
 		if e := json.Unmarshal(body, &result.Payload); e != nil {
 			result.Error = e
 			return
@@ -2,21 +2,53 @@ package nico
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/miekg/dns"
+	"github.com/hype5/nicotrans-go/pkg/resolver"
 )
 
-type hostCache struct {
-	checked time.Time
-	addr    string
+// Resolver 니코니코 서버 주소를 조회할 때 사용할 DNS 리졸버
+// 호스트 파일로 니코니코 서버를 돌려놓기 때문에 시스템 DNS 를 사용할 수 없습니다
+var Resolver = &resolver.Resolver{
+	Upstreams: []resolver.Upstream{{Protocol: "udp", Address: "1.1.1.1:53"}},
 }
 
-var hostCaches = map[string]hostCache{
-	"nmsg.nicovideo.jp": {},
+// Hosts 니코트랜스가 가로채는 니코니코 호스트
+// 호스트 파일, DNS 서버, 인증서 모두 이 목록을 사용합니다
+var Hosts = []string{"nmsg.nicovideo.jp"}
+
+// Domains 루트 인증서의 이름 제약 조건이 허용하는 니코니코 도메인
+// 가로챌 호스트는 모두 이 도메인 아래에 있어야 하며, 그렇다면 호스트가 늘어나도 루트 인증서를 다시 설치할 필요가 없습니다
+var Domains = []string{"nicovideo.jp"}
+
+// InDomains 호스트가 Domains 중 하나의 아래에 있는지?
+func InDomains(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, domain := range Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsHost 니코트랜스가 가로채는 호스트인지?
+func IsHost(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+
+	for _, h := range Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+
+	return false
 }
 
 var dialer = &net.Dialer{
@@ -28,36 +60,30 @@ var dialer = &net.Dialer{
 var transport = &http.Transport{
 	Dial: dialer.Dial,
 	DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-		address := strings.Split(addr, ":")
-
-		// 수동으로 업데이트할 호스트 주소라면 호스트 캐시 확인하기
-		if d, ok := hostCaches[address[0]]; ok {
-			// 60초 이상 지났다면 DNS 업데이트하기
-			if time.Since(d.checked).Seconds() >= 60 {
-				// DNS 서버에 A 레코드로 요청하기
-				c := dns.Client{}
-				m := dns.Msg{}
-				m.SetQuestion(address[0]+".", dns.TypeA)
-
-				r, _, e := c.Exchange(&m, "1.1.1.1:53")
-				if e != nil {
-					// TODO: 더 나은 오류 핸들링
-					panic(e)
-				}
-
-				// 불러온 아이피 캐시하기
-				addr = r.Answer[0].(*dns.A).A.String() + ":" + address[1]
-				hostCaches[address[0]] = hostCache{
-					addr:    addr,
-					checked: time.Now(),
-				}
-			} else {
-				// 캐시된 호스트의 아이피 주소 사용하기
-				addr = d.addr
+		host, port, e := net.SplitHostPort(addr)
+		if e != nil {
+			return nil, e
+		}
+
+		// 가로채는 호스트는 호스트 파일을 거치지 않도록 리졸버로 직접 조회하기
+		if !IsHost(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		ips, e := Resolver.LookupIP(ctx, host)
+		if e != nil {
+			return nil, fmt.Errorf("%s 주소를 조회할 수 없습니다: %s", host, e)
+		}
+
+		// 연결될 때까지 조회한 주소를 순서대로 시도하기
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, e = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); e == nil {
+				return conn, nil
 			}
 		}
 
-		return dialer.DialContext(ctx, network, addr)
+		return nil, e
 	},
 }
 
@@ -0,0 +1,229 @@
+package onboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+// 내려받을 인증서 파일 이름
+const (
+	PEMPath          = "/nicotrans.crt"
+	DERPath          = "/nicotrans.cer"
+	MobileConfigPath = "/nicotrans.mobileconfig"
+)
+
+// Fingerprint 인증서의 SHA-256 지문을 AA:BB:CC 형식으로 반환합니다
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// MobileConfig 아이폰, 맥에서 루트 인증서를 설치할 수 있는 구성 프로파일을 만듭니다
+// 같은 인증서라면 항상 같은 UUID 를 사용하기 때문에 다시 설치해도 프로파일이 늘어나지 않습니다
+func MobileConfig(cert *x509.Certificate) ([]byte, error) {
+	sum := sha256.Sum256(cert.Raw)
+
+	var b bytes.Buffer
+	e := mobileConfigTemplate.Execute(&b, map[string]string{
+		"Name":        cert.Subject.CommonName,
+		"Certificate": base64.StdEncoding.EncodeToString(cert.Raw),
+		"PayloadUUID": uuid(sum[:16]),
+		"ProfileUUID": uuid(sum[16:]),
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	return b.Bytes(), nil
+}
+
+// uuid 16바이트로 UUID 버전 4 형식 문자열을 만듭니다
+func uuid(b []byte) string {
+	u := make([]byte, 16)
+	copy(u, b)
+
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// Handler 루트 인증서 설치 안내 페이지와 인증서 파일을 응답하는 핸들러를 만듭니다
+// 인증서가 교체될 수 있기 때문에 요청마다 ca 함수로 현재 루트 인증서를 가져옵니다
+func Handler(ca func() *x509.Certificate) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		cert := ca()
+
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+			e := pageTemplate.Execute(w, map[string]interface{}{
+				"Name":             cert.Subject.CommonName,
+				"Fingerprint":      Fingerprint(cert),
+				"NotAfter":         cert.NotAfter.Format(time.RFC3339),
+				"PEMPath":          PEMPath,
+				"DERPath":          DERPath,
+				"MobileConfigPath": MobileConfigPath,
+			})
+			if e != nil {
+				http.Error(w, e.Error(), http.StatusInternalServerError)
+			}
+		case PEMPath:
+			serveFile(w, "application/x-pem-file", PEMPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+		case DERPath:
+			// 안드로이드는 이 형식이어야 바로 설치 화면을 띄움
+			serveFile(w, "application/x-x509-ca-cert", DERPath, cert.Raw)
+		case MobileConfigPath:
+			data, e := MobileConfig(cert)
+			if e != nil {
+				http.Error(w, e.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			serveFile(w, "application/x-apple-aspen-config", MobileConfigPath, data)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func serveFile(w http.ResponseWriter, contentType string, name string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.TrimPrefix(name, "/")+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+var mobileConfigTemplate = textTemplate.Must(textTemplate.New("mobileconfig").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>nicotrans.cer</string>
+			<key>PayloadContent</key>
+			<data>{{.Certificate}}</data>
+			<key>PayloadDisplayName</key>
+			<string>{{html .Name}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.github.hype5.nicotrans.root.{{.PayloadUUID}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{.PayloadUUID}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDescription</key>
+	<string>니코니코 코멘트 번역을 위한 니코트랜스 루트 인증서</string>
+	<key>PayloadDisplayName</key>
+	<string>NicoTrans</string>
+	<key>PayloadIdentifier</key>
+	<string>com.github.hype5.nicotrans</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{.ProfileUUID}}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`))
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="ko">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>니코트랜스 인증서 설치</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; line-height: 1.6; }
+code { word-break: break-all; background: #f4f4f4; padding: 0.1em 0.3em; }
+a.button { display: inline-block; margin: 0.2em 0.5em 0.2em 0; padding: 0.4em 0.8em; border: 1px solid #888; border-radius: 4px; text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>니코트랜스 인증서 설치</h1>
+
+<p>이 기기에서 니코트랜스를 사용하려면 아래 루트 인증서를 설치하고 신뢰해야 합니다.</p>
+
+<p>
+<a class="button" href="{{.PEMPath}}">PEM (.crt)</a>
+<a class="button" href="{{.DERPath}}">DER (.cer)</a>
+<a class="button" href="{{.MobileConfigPath}}">iOS/macOS 프로파일</a>
+</p>
+
+<p>
+이름: {{.Name}}<br>
+만료: {{.NotAfter}}<br>
+SHA-256 지문: <code>{{.Fingerprint}}</code>
+</p>
+
+<p>설치하기 전에 니코트랜스를 실행한 컴퓨터에 표시된 지문과 같은지 확인하세요.</p>
+
+<h2>윈도우</h2>
+<ol>
+<li>DER (.cer) 파일을 내려받아 실행합니다.</li>
+<li><b>인증서 설치</b> 를 누르고 <b>신뢰할 수 있는 루트 인증 기관</b> 저장소를 선택합니다.</li>
+</ol>
+
+<h2>macOS</h2>
+<ol>
+<li>iOS/macOS 프로파일을 내려받고 <b>시스템 설정 &gt; 개인정보 보호 및 보안 &gt; 프로파일</b> 에서 설치합니다.</li>
+<li>또는 PEM 파일을 키체인 접근에 추가한 뒤 <b>항상 신뢰</b> 로 설정합니다.</li>
+</ol>
+
+<h2>iOS, iPadOS</h2>
+<ol>
+<li>사파리로 iOS/macOS 프로파일을 내려받고 <b>설정 &gt; 일반 &gt; VPN 및 기기 관리</b> 에서 설치합니다.</li>
+<li><b>설정 &gt; 일반 &gt; 정보 &gt; 인증서 신뢰 설정</b> 에서 {{.Name}} 을 켭니다.</li>
+</ol>
+
+<h2>안드로이드</h2>
+<ol>
+<li>DER (.cer) 파일을 내려받습니다.</li>
+<li><b>설정 &gt; 보안 &gt; 암호화 및 사용자 인증 정보 &gt; 인증서 설치 &gt; CA 인증서</b> 에서 내려받은 파일을 선택합니다.</li>
+<li>안드로이드 7 이상의 앱은 사용자 인증서를 신뢰하지 않을 수 있습니다. 브라우저는 대부분 동작합니다.</li>
+</ol>
+
+<h2>리눅스</h2>
+<ol>
+<li>PEM 파일을 <code>/usr/local/share/ca-certificates/nicotrans.crt</code> 로 복사하고 <code>sudo update-ca-certificates</code> 를 실행합니다.</li>
+<li>페도라는 <code>/etc/pki/ca-trust/source/anchors/</code> 에 복사하고 <code>sudo update-ca-trust extract</code> 를 실행합니다.</li>
+</ol>
+
+<h2>파이어폭스</h2>
+<ol>
+<li><b>설정 &gt; 개인 정보 및 보안 &gt; 인증서 보기 &gt; 인증 기관 &gt; 가져오기</b> 에서 PEM 파일을 선택합니다.</li>
+<li><b>이 인증 기관이 웹 사이트를 식별하도록 신뢰</b> 를 선택합니다.</li>
+</ol>
+</body>
+</html>
+`))
@@ -0,0 +1,253 @@
+package onboard
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCA 테스트용 루트 인증서를 만듭니다
+func newCA(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+
+	priv, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, e := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return cert
+}
+
+// plistValues 프로파일의 키마다 값을 나온 순서대로 모읍니다, XML 이 올바르지 않다면 실패합니다
+func plistValues(t *testing.T, data []byte) map[string][]string {
+	t.Helper()
+
+	values := map[string][]string{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var key string
+	var text strings.Builder
+
+	for {
+		token, e := decoder.Token()
+		if e == io.EOF {
+			return values
+		}
+
+		if e != nil {
+			t.Fatalf("올바른 XML 이 아닙니다: %v", e)
+		}
+
+		switch v := token.(type) {
+		case xml.StartElement:
+			text.Reset()
+		case xml.CharData:
+			text.Write(v)
+		case xml.EndElement:
+			switch v.Name.Local {
+			case "key":
+				key = text.String()
+			case "string", "data", "integer":
+				values[key] = append(values[key], text.String())
+			}
+		}
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$`)
+
+func TestFingerprint(t *testing.T) {
+	cert := newCA(t, "NicoTrans Root CA")
+	sum := sha256.Sum256(cert.Raw)
+
+	got := Fingerprint(cert)
+
+	if !regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`).MatchString(got) {
+		t.Errorf("%s: AA:BB:CC 형식이 아닙니다", got)
+	}
+
+	if strings.ReplaceAll(got, ":", "") != strings.ToUpper(hex.EncodeToString(sum[:])) {
+		t.Errorf("%s, 기대값 %X", got, sum)
+	}
+}
+
+func TestMobileConfig(t *testing.T) {
+	tests := []string{
+		"NicoTrans Root CA",
+		`<NicoTrans & "Root" CA's>`,
+		"니코트랜스 루트 인증서",
+	}
+
+	for _, name := range tests {
+		cert := newCA(t, name)
+
+		data, e := MobileConfig(cert)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		values := plistValues(t, data)
+
+		// 인증서 이름은 XML 로 올바르게 이스케이프돼야 함
+		if got := values["PayloadDisplayName"]; len(got) != 2 || got[0] != name || got[1] != "NicoTrans" {
+			t.Errorf("%s: 이름 %q", name, got)
+		}
+
+		if got := values["PayloadContent"]; len(got) != 1 || got[0] != base64.StdEncoding.EncodeToString(cert.Raw) {
+			t.Errorf("%s: 인증서가 들어있지 않습니다", name)
+		}
+
+		if got := values["PayloadType"]; len(got) != 2 || got[0] != "com.apple.security.root" || got[1] != "Configuration" {
+			t.Errorf("%s: 종류 %q", name, got)
+		}
+
+		uuids := values["PayloadUUID"]
+		if len(uuids) != 2 || uuids[0] == uuids[1] || !uuidPattern.MatchString(uuids[0]) || !uuidPattern.MatchString(uuids[1]) {
+			t.Errorf("%s: UUID %q", name, uuids)
+		}
+
+		// 같은 인증서라면 다시 만들어도 같은 UUID 를 사용해야 함
+		again, e := MobileConfig(cert)
+		if e != nil || !bytes.Equal(data, again) {
+			t.Errorf("%s: 같은 인증서로 만든 프로파일이 다릅니다 (%v)", name, e)
+		}
+
+		other, e := MobileConfig(newCA(t, name))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if otherUUIDs := plistValues(t, other)["PayloadUUID"]; otherUUIDs[0] == uuids[0] || otherUUIDs[1] == uuids[1] {
+			t.Errorf("%s: 다른 인증서인데 UUID 가 같습니다", name)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	first := newCA(t, `NicoTrans <Root> CA`)
+	second := newCA(t, "NicoTrans Root CA 2")
+
+	var calls int32
+	current := first
+
+	handler := Handler(func() *x509.Certificate {
+		atomic.AddInt32(&calls, 1)
+		return current
+	})
+
+	tests := []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+		filename    string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "text/html; charset=utf-8", ""},
+		{http.MethodHead, "/", http.StatusOK, "text/html; charset=utf-8", ""},
+		{http.MethodGet, PEMPath, http.StatusOK, "application/x-pem-file", "nicotrans.crt"},
+		{http.MethodGet, DERPath, http.StatusOK, "application/x-x509-ca-cert", "nicotrans.cer"},
+		{http.MethodGet, MobileConfigPath, http.StatusOK, "application/x-apple-aspen-config", "nicotrans.mobileconfig"},
+		{http.MethodGet, "/server.key", http.StatusNotFound, "", ""},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed, "", ""},
+		{http.MethodDelete, PEMPath, http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: %d, 기대값 %d", test.method, test.path, w.Code, test.status)
+			continue
+		}
+
+		if test.status == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("%s %s: Allow %q", test.method, test.path, w.Header().Get("Allow"))
+		}
+
+		if test.contentType != "" && w.Header().Get("Content-Type") != test.contentType {
+			t.Errorf("%s %s: Content-Type %q, 기대값 %q", test.method, test.path, w.Header().Get("Content-Type"), test.contentType)
+		}
+
+		if test.filename != "" {
+			if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="`+test.filename+`"` {
+				t.Errorf("%s %s: Content-Disposition %q", test.method, test.path, got)
+			}
+
+			if w.Header().Get("Cache-Control") != "no-cache" {
+				t.Errorf("%s %s: Cache-Control %q", test.method, test.path, w.Header().Get("Cache-Control"))
+			}
+		}
+	}
+
+	get := func(path string) []byte {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body, _ := ioutil.ReadAll(w.Body)
+		return body
+	}
+
+	// 내려받은 인증서는 루트 인증서와 같아야 함
+	if block, _ := pem.Decode(get(PEMPath)); block == nil || block.Type != "CERTIFICATE" || !bytes.Equal(block.Bytes, first.Raw) {
+		t.Error("PEM 인증서가 루트 인증서와 다릅니다")
+	}
+
+	if !bytes.Equal(get(DERPath), first.Raw) {
+		t.Error("DER 인증서가 루트 인증서와 다릅니다")
+	}
+
+	page := string(get("/"))
+	if !strings.Contains(page, Fingerprint(first)) || !strings.Contains(page, "NicoTrans &lt;Root&gt; CA") || strings.Contains(page, "<Root>") {
+		t.Error("안내 페이지에 지문이나 이스케이프한 이름이 없습니다")
+	}
+
+	// 루트 인증서를 교체하면 다음 요청부터 새 인증서를 응답해야 함
+	current = second
+
+	if !bytes.Equal(get(DERPath), second.Raw) || !strings.Contains(string(get("/")), Fingerprint(second)) {
+		t.Error("교체한 루트 인증서를 응답하지 않습니다")
+	}
+
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Error("루트 인증서를 가져오지 않았습니다")
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PAC 지정한 호스트만 프록시를 거치고 나머지는 직접 연결하는 프록시 자동 설정 파일을 만듭니다
+func PAC(proxyAddr string, hosts []string) string {
+	var b strings.Builder
+
+	b.WriteString("function FindProxyForURL(url, host) {\n")
+	b.WriteString("\thost = host.toLowerCase();\n\n")
+
+	if len(hosts) > 0 {
+		conditions := make([]string, len(hosts))
+		for i, host := range hosts {
+			conditions[i] = "host == " + strconv.Quote(strings.ToLower(host))
+		}
+
+		b.WriteString("\tif (" + strings.Join(conditions, " ||\n\t\t") + ") {\n")
+		b.WriteString("\t\treturn " + strconv.Quote("PROXY "+proxyAddr) + ";\n")
+		b.WriteString("\t}\n\n")
+	}
+
+	b.WriteString("\treturn \"DIRECT\";\n")
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// PACHandler 프록시 자동 설정 파일을 응답하는 핸들러를 만듭니다
+// 프록시 주소는 클라이언트가 요청한 호스트를 그대로 사용합니다
+func PACHandler(hosts []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/proxy.pac", "/wpad.dat":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, PAC(r.Host, hosts))
+	})
+}
@@ -0,0 +1,431 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrLocalTarget 가로채지 않는 호스트가 루프백, 링크 로컬이나 사설 주소일 때 반환됩니다
+// 프록시를 거쳐 관리 API 같은 로컬 서비스나 내부망의 다른 기기에 접근하지 못하도록 막습니다
+var ErrLocalTarget = errors.New("루프백, 링크 로컬이나 사설 주소로는 연결할 수 없습니다")
+
+// 연결하지 않는 사설 대역 (RFC 1918, RFC 4193)
+var privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+// 다음 홉으로 전달하면 안 되는 헤더
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy HTTP 포워드 프록시
+// 가로챌 호스트로 가는 요청은 직접 TLS 로 받아 Handler 로 처리하고, 나머지는 그대로 터널링합니다
+type Proxy struct {
+	// 가로챌 호스트인지?
+	Intercept func(host string) bool
+	// 가로챈 연결에 사용할 TLS 설정
+	TLSConfig *tls.Config
+	// 가로챈 요청을 처리할 핸들러
+	Handler http.Handler
+	// 프록시 요청이 아닌 요청을 처리할 핸들러
+	Fallback http.Handler
+	// 프록시를 사용할 수 있는 클라이언트 대역, 비어있으면 루프백 주소만 사용할 수 있습니다
+	Clients []*net.IPNet
+
+	Transport http.RoundTripper
+	// 터널링과 요청 전달에 사용할 다이얼러, 루프백, 링크 로컬과 사설 주소로는 연결하지 않습니다
+	Dialer *net.Dialer
+
+	// 가로챈 연결을 처리 중인 서버
+	mu       sync.Mutex
+	servers  map[*http.Server]struct{}
+	shutdown bool
+}
+
+// New 프록시를 만듭니다
+func New(intercept func(string) bool, config *tls.Config, handler http.Handler) *Proxy {
+	p := &Proxy{
+		Intercept: intercept,
+		TLSConfig: config,
+		Handler:   handler,
+		Dialer:    &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+
+	p.Transport = &http.Transport{
+		DialContext:           p.dial,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	}
+
+	return p
+}
+
+// dial 이름을 조회한 뒤 실제로 연결할 주소가 로컬이나 사설 주소가 아닌지 확인하고 연결합니다
+// 조회한 뒤에 확인하기 때문에 DNS 응답을 바꿔 로컬이나 사설 주소로 연결하게 만들 수 없습니다
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := *p.Dialer
+	control := dialer.Control
+
+	dialer.Control = func(network, address string, c syscall.RawConn) error {
+		host, _, e := net.SplitHostPort(address)
+		if e != nil {
+			return e
+		}
+
+		if isLocal(net.ParseIP(host)) {
+			return ErrLocalTarget
+		}
+
+		if control != nil {
+			return control(network, address, c)
+		}
+
+		return nil
+	}
+
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// isLocal 루프백, 링크 로컬, 사설, 지정되지 않은 주소인지?
+// 프록시를 사용할 수 있는 클라이언트가 내부망을 거쳐 가는 발판으로 쓰지 못하도록 사설 주소도 막습니다
+func isLocal(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+
+	return networks
+}
+
+// allowed 프록시를 사용할 수 있는 클라이언트인지?
+func (p *Proxy) allowed(remoteAddr string) bool {
+	host, _, e := net.SplitHostPort(remoteAddr)
+	if e != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	if len(p.Clients) == 0 {
+		return ip.IsLoopback()
+	}
+
+	for _, network := range p.Clients {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dialError 연결 오류를 응답합니다, 로컬이나 사설 주소로 연결하려 했다면 403 으로 응답합니다
+func dialError(w http.ResponseWriter, e error) {
+	if errors.Is(e, ErrLocalTarget) {
+		http.Error(w, ErrLocalTarget.Error(), http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, e.Error(), http.StatusBadGateway)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed(r.RemoteAddr) {
+		http.Error(w, fmt.Sprintf("%s 는 프록시를 사용할 수 없는 클라이언트입니다", r.RemoteAddr), http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodConnect:
+		p.connect(w, r)
+	case r.URL.IsAbs():
+		p.forward(w, r)
+	case p.Fallback != nil:
+		p.Fallback.ServeHTTP(w, r)
+	default:
+		http.Error(w, "프록시 요청이 아닙니다", http.StatusBadRequest)
+	}
+}
+
+// connect CONNECT 요청을 가로채거나 터널링합니다
+func (p *Proxy) connect(w http.ResponseWriter, r *http.Request) {
+	host, _, e := net.SplitHostPort(r.Host)
+	if e != nil {
+		host = r.Host
+	}
+
+	var target net.Conn
+
+	// 가로채지 않는 호스트라면 먼저 연결해보기
+	intercept := p.Intercept(host)
+	if !intercept {
+		if target, e = p.dial(r.Context(), "tcp", r.Host); e != nil {
+			dialError(w, e)
+			return
+		}
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "연결을 가로챌 수 없습니다", http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, e := hijacker.Hijack()
+	if e != nil {
+		if target != nil {
+			target.Close()
+		}
+
+		return
+	}
+
+	if _, e := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); e != nil {
+		conn.Close()
+
+		if target != nil {
+			target.Close()
+		}
+
+		return
+	}
+
+	client := &bufferedConn{Conn: conn, r: rw.Reader}
+
+	if intercept {
+		p.serveTLS(client)
+	} else {
+		tunnel(client, target)
+	}
+}
+
+// serveTLS 가로챈 연결을 TLS 서버로 받아 Handler 로 처리합니다
+func (p *Proxy) serveTLS(conn net.Conn) {
+	var served int32
+
+	server := &http.Server{Handler: p.Handler}
+	server.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.StoreInt32(&served, 1)
+		case http.StateClosed, http.StateHijacked:
+			p.untrack(server)
+		}
+	}
+
+	if !p.track(server) {
+		conn.Close()
+		return
+	}
+
+	// 연결이 하나뿐인 리스너라서 Serve 는 바로 반환되지만 연결은 계속 처리됩니다
+	server.Serve(&singleListener{conn: tls.Server(conn, p.TLSConfig)})
+
+	// 연결을 받기 전에 종료됐다면 직접 닫기
+	if atomic.LoadInt32(&served) == 0 {
+		conn.Close()
+		p.untrack(server)
+	}
+}
+
+// track 가로챈 연결을 처리할 서버를 기록합니다, 종료 중이라면 false 를 반환합니다
+func (p *Proxy) track(server *http.Server) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.shutdown {
+		return false
+	}
+
+	if p.servers == nil {
+		p.servers = map[*http.Server]struct{}{}
+	}
+
+	p.servers[server] = struct{}{}
+
+	return true
+}
+
+func (p *Proxy) untrack(server *http.Server) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.servers, server)
+}
+
+// Shutdown 가로챈 연결을 더 받지 않고 처리 중인 요청이 끝날 때까지 기다립니다
+// ctx 가 끝나면 남은 연결을 닫고 ctx 의 오류를 반환합니다, 터널링 중인 연결은 기다리지 않습니다
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.shutdown = true
+
+	servers := make([]*http.Server, 0, len(p.servers))
+	for server := range p.servers {
+		servers = append(servers, server)
+	}
+	p.mu.Unlock()
+
+	results := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			e := server.Shutdown(ctx)
+			if e != nil {
+				server.Close()
+			}
+
+			results <- e
+		}(server)
+	}
+
+	var first error
+	for range servers {
+		if e := <-results; e != nil && first == nil {
+			first = e
+		}
+	}
+
+	return first
+}
+
+// forward 일반 HTTP 프록시 요청을 전달합니다
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	if p.Intercept(r.URL.Hostname()) {
+		p.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	removeHopHeaders(req.Header)
+
+	res, e := p.Transport.RoundTrip(req)
+	if e != nil {
+		dialError(w, e)
+		return
+	}
+
+	defer res.Body.Close()
+
+	removeHopHeaders(res.Header)
+
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+// tunnel 두 연결 사이의 데이터를 그대로 주고받습니다
+func tunnel(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+
+		// 한쪽이 끝나면 반대쪽에도 알리기
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	go pipe(a, b)
+	go pipe(b, a)
+
+	wg.Wait()
+	a.Close()
+	b.Close()
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+// bufferedConn 하이재킹할 때 이미 읽어둔 데이터를 먼저 돌려주는 연결
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Conn.Close()
+}
+
+// singleListener 연결 하나만 돌려주는 리스너
+type singleListener struct {
+	conn net.Conn
+	once sync.Once
+}
+
+func (l *singleListener) Accept() (net.Conn, error) {
+	var c net.Conn
+	l.once.Do(func() {
+		c = l.conn
+	})
+
+	if c == nil {
+		return nil, io.EOF
+	}
+
+	return c, nil
+}
+
+func (l *singleListener) Close() error {
+	return nil
+}
+
+func (l *singleListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
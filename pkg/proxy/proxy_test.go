@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// startProxy 니코니코 코멘트 서버만 가로채는 프록시를 시작합니다
+func startProxy(t *testing.T, handler http.Handler) (*Proxy, *url.URL) {
+	t.Helper()
+
+	// httptest 의 인증서로 가로챈 연결 받기
+	upstream := httptest.NewTLSServer(http.NotFoundHandler())
+	config := &tls.Config{Certificates: upstream.TLS.Certificates}
+	upstream.Close()
+
+	p := New(func(host string) bool { return host == "nmsg.nicovideo.jp" }, config, handler)
+	p.Fallback = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	})
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+
+	return p, u
+}
+
+func proxyClient(u *url.URL) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(u),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+func get(t *testing.T, c *http.Client, u string) (int, string) {
+	t.Helper()
+
+	res, e := c.Get(u)
+	if e != nil {
+		return 0, e.Error()
+	}
+
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+
+	return res.StatusCode, string(body)
+}
+
+func TestProxyIntercept(t *testing.T) {
+	_, u := startProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("intercepted " + r.Host + r.URL.Path))
+	}))
+
+	c := proxyClient(u)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://nmsg.nicovideo.jp/api.json/", "intercepted nmsg.nicovideo.jp/api.json/"},
+		{"http://nmsg.nicovideo.jp/api.json/", "intercepted nmsg.nicovideo.jp/api.json/"},
+	}
+
+	for _, test := range tests {
+		if status, body := get(t, c, test.url); status != http.StatusOK || body != test.want {
+			t.Errorf("%s: %d %s, 기대값 %s", test.url, status, body, test.want)
+		}
+	}
+
+	// 프록시 요청이 아니라면 Fallback 으로 처리해야 함
+	if status, body := get(t, http.DefaultClient, u.String()+"/proxy.pac"); status != http.StatusOK || body != "fallback" {
+		t.Errorf("%d %s", status, body)
+	}
+}
+
+func TestProxyLocalTarget(t *testing.T) {
+	// 프록시를 거쳐 접근하면 안 되는 로컬 서비스
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("로컬 서비스에 연결됐습니다: %s", r.URL)
+	}))
+	defer local.Close()
+
+	_, u := startProxy(t, http.NotFoundHandler())
+	c := proxyClient(u)
+
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	for _, target := range []string{
+		local.URL,
+		"http://localhost:" + port,
+		"http://[::1]:" + port,
+		"http://0.0.0.0:" + port,
+	} {
+		if status, body := get(t, c, target); status != http.StatusForbidden {
+			t.Errorf("%s: %d %s", target, status, body)
+		}
+	}
+
+	// CONNECT 도 막아야 함
+	res, e := c.Get("https://127.0.0.1:" + port)
+	if e == nil {
+		res.Body.Close()
+		t.Error("CONNECT 로 로컬 서비스에 연결됐습니다")
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.0.2", true},
+		{"::ffff:10.0.0.1", true},
+		{"fd00::1", true},
+		{"203.104.248.135", false},
+		{"172.32.0.1", false},
+		{"100.64.0.1", false},
+		{"2001:db8::1", false},
+	}
+
+	for _, test := range tests {
+		if got := isLocal(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("%s: %v, 기대값 %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestProxyClients(t *testing.T) {
+	p, u := startProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("intercepted"))
+	}))
+
+	c := proxyClient(u)
+
+	_, lan, _ := net.ParseCIDR("192.168.0.0/24")
+	p.Clients = []*net.IPNet{lan}
+
+	// 허용하지 않은 클라이언트는 가로챈 호스트와 PAC 파일도 사용할 수 없어야 함
+	if status, _ := get(t, c, "http://nmsg.nicovideo.jp/api.json/"); status != http.StatusForbidden {
+		t.Errorf("허용하지 않은 클라이언트 응답 %d", status)
+	}
+
+	if status, _ := get(t, http.DefaultClient, u.String()+"/proxy.pac"); status != http.StatusForbidden {
+		t.Errorf("허용하지 않은 클라이언트 PAC 응답 %d", status)
+	}
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	p.Clients = append(p.Clients, loopback)
+
+	if status, body := get(t, c, "http://nmsg.nicovideo.jp/api.json/"); status != http.StatusOK || body != "intercepted" {
+		t.Errorf("허용한 클라이언트 응답 %d %s", status, body)
+	}
+
+	tests := []struct {
+		clients    []*net.IPNet
+		remoteAddr string
+		want       bool
+	}{
+		{nil, "127.0.0.1:1234", true},
+		{nil, "[::1]:1234", true},
+		{nil, "192.168.0.10:1234", false},
+		{[]*net.IPNet{lan}, "192.168.0.10:1234", true},
+		{[]*net.IPNet{lan}, "192.168.1.10:1234", false},
+		{[]*net.IPNet{lan}, "127.0.0.1:1234", false},
+		{[]*net.IPNet{lan}, "invalid", false},
+	}
+
+	for _, test := range tests {
+		p := &Proxy{Clients: test.clients}
+
+		if got := p.allowed(test.remoteAddr); got != test.want {
+			t.Errorf("%v %s: %v, 기대값 %v", test.clients, test.remoteAddr, got, test.want)
+		}
+	}
+}
+
+func TestProxyShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+
+	p, u := startProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("slow"))
+	}))
+
+	done := make(chan string)
+	go func() {
+		_, body := get(t, proxyClient(u), "https://nmsg.nicovideo.jp/")
+		done <- body
+	}()
+
+	<-started
+
+	// 처리 중인 요청이 끝날 때까지 기다려야 함
+	start := time.Now()
+	if e := p.Shutdown(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("요청을 기다리지 않았습니다: %s", elapsed)
+	}
+
+	if body := <-done; body != "slow" {
+		t.Errorf("%s, 기대값 slow", body)
+	}
+
+	p.mu.Lock()
+	servers := len(p.servers)
+	p.mu.Unlock()
+
+	if servers != 0 {
+		t.Errorf("남은 서버 %d", servers)
+	}
+
+	// 종료한 뒤에 가로챈 연결은 닫아야 함
+	if _, e := proxyClient(u).Get("https://nmsg.nicovideo.jp/"); e == nil {
+		t.Error("종료한 뒤에 요청을 처리했습니다")
+	}
+}
@@ -0,0 +1,249 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats 캐시 통계
+type CacheStats struct {
+	// 만료되지 않은 항목을 사용한 횟수
+	Hits uint64
+	// 만료된 항목을 사용하며 다시 조회한 횟수
+	StaleHits uint64
+	// 조회 실패를 기억해 바로 실패한 횟수
+	NegativeHits uint64
+	// 캐시에 없어 직접 조회한 횟수
+	Misses uint64
+	// 캐시에 없었지만 같은 항목을 조회 중인 다른 요청의 결과를 받은 횟수
+	Shared uint64
+	// 업스트림에 실제로 조회한 횟수
+	Refreshes uint64
+	// 조회에 실패한 횟수
+	Errors uint64
+	// 저장된 항목 수
+	Entries int
+}
+
+// CacheEntry 캐시에 저장된 조회 결과
+type CacheEntry struct {
+	Host    string
+	IPs     []net.IP
+	Error   error
+	Expires time.Time
+}
+
+type cacheEntry struct {
+	ips        []net.IP
+	err        error
+	expires    time.Time
+	refreshing bool
+}
+
+var errFetchPanic = errors.New("주소를 조회하는 중 패닉이 발생했습니다")
+
+type cacheCall struct {
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+// wait 조회가 끝나거나 ctx 가 끝날 때까지 기다립니다
+// ctx 가 먼저 끝나도 조회는 멈추지 않고 같은 결과를 기다리는 다른 요청에게 전달됩니다
+func (call *cacheCall) wait(ctx context.Context) ([]net.IP, error) {
+	select {
+	case <-call.done:
+		return call.ips, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cache 여러 고루틴에서 동시에 사용할 수 있는 조회 결과 캐시
+// 항목은 DNS 응답의 TTL 동안 유지되며, 만료된 뒤에도 StaleTTL 동안은
+// 이전 결과를 돌려주면서 백그라운드에서 다시 조회합니다
+type Cache struct {
+	// 만료된 결과를 다시 조회하는 동안 사용할 수 있는 시간
+	StaleTTL time.Duration
+	// 조회 실패를 기억할 시간
+	NegativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	calls   map[string]*cacheCall
+	stats   CacheStats
+}
+
+// cacheFetcher 캐시에 없는 항목을 조회합니다
+type cacheFetcher func(ctx context.Context) ([]net.IP, time.Duration, error)
+
+// get 캐시에서 항목을 찾고, 없다면 fetch 로 조회해 저장합니다
+// 같은 항목을 동시에 요청하면 조회는 한 번만 합니다
+func (c *Cache) get(ctx context.Context, key string, fetch cacheFetcher) ([]net.IP, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+
+	if e, ok := c.entries[key]; ok {
+		if now.Before(e.expires) {
+			if e.err != nil {
+				c.stats.NegativeHits++
+			} else {
+				c.stats.Hits++
+			}
+
+			c.mu.Unlock()
+			return e.ips, e.err
+		}
+
+		// 만료됐지만 아직 사용할 수 있다면 이전 결과를 주고 백그라운드에서 갱신하기
+		if e.err == nil && now.Before(e.expires.Add(c.StaleTTL)) {
+			c.stats.StaleHits++
+
+			if !e.refreshing {
+				e.refreshing = true
+				go c.fetchDetached(key, fetch)
+			}
+
+			c.mu.Unlock()
+			return e.ips, nil
+		}
+	}
+
+	// 이미 조회 중이라면 결과 기다리기
+	if call, ok := c.calls[key]; ok {
+		c.stats.Shared++
+		c.mu.Unlock()
+
+		return call.wait(ctx)
+	}
+
+	c.stats.Misses++
+
+	call := &cacheCall{done: make(chan struct{})}
+
+	if c.calls == nil {
+		c.calls = map[string]*cacheCall{}
+	}
+	c.calls[key] = call
+
+	c.mu.Unlock()
+
+	// 처음 요청한 클라이언트가 연결을 끊어도 기다리는 다른 요청을 위해 조회는 끝까지 하기
+	go func() {
+		call.ips, call.err = c.fetchDetached(key, fetch)
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	return call.wait(ctx)
+}
+
+// fetchDetached 요청한 클라이언트의 컨텍스트와 상관없이 항목을 조회해 캐시에 저장합니다
+// 백그라운드에서 실행되므로 조회 중 패닉이 발생하면 프로세스를 멈추지 않고 errFetchPanic 을 반환합니다
+func (c *Cache) fetchDetached(key string, fetch cacheFetcher) (ips []net.IP, e error) {
+	defer func() {
+		if recover() == nil {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.stats.Errors++
+
+		if entry, ok := c.entries[key]; ok {
+			entry.refreshing = false
+		}
+
+		ips, e = nil, errFetchPanic
+	}()
+
+	return c.fetch(context.Background(), key, fetch)
+}
+
+// fetch 항목을 조회해 캐시에 저장합니다
+func (c *Cache) fetch(ctx context.Context, key string, fetch cacheFetcher) ([]net.IP, error) {
+	ips, ttl, e := fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Refreshes++
+
+	if c.entries == nil {
+		c.entries = map[string]*cacheEntry{}
+	}
+
+	if e != nil {
+		c.stats.Errors++
+
+		// 이전 결과가 아직 사용할 수 있다면 남겨두기
+		if old, ok := c.entries[key]; ok && old.err == nil && time.Now().Before(old.expires.Add(c.StaleTTL)) {
+			old.refreshing = false
+			return nil, e
+		}
+
+		// 제한 시간이 지나거나 취소된 조회는 업스트림의 실패가 아니므로 기억하지 않기
+		if errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded) {
+			return nil, e
+		}
+
+		if c.NegativeTTL > 0 {
+			c.entries[key] = &cacheEntry{err: e, expires: time.Now().Add(c.NegativeTTL)}
+		} else {
+			delete(c.entries, key)
+		}
+
+		return nil, e
+	}
+
+	c.entries[key] = &cacheEntry{ips: ips, expires: time.Now().Add(ttl)}
+
+	return ips, nil
+}
+
+// Stats 캐시 통계를 반환합니다
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+
+	return stats
+}
+
+// Entries 캐시에 저장된 항목을 반환합니다
+func (c *Cache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for key, e := range c.entries {
+		entries = append(entries, CacheEntry{
+			Host:    key[strings.Index(key, " ")+1:],
+			IPs:     e.ips,
+			Error:   e.err,
+			Expires: e.expires,
+		})
+	}
+
+	return entries
+}
+
+// Flush 캐시를 비웁니다
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+}
@@ -0,0 +1,437 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fetcher 호출 횟수를 세며 정해진 결과를 반환하는 조회 함수를 만듭니다
+func fetcher(calls *int32, ips []net.IP, ttl time.Duration, fail error) cacheFetcher {
+	return func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		atomic.AddInt32(calls, 1)
+		return ips, ttl, fail
+	}
+}
+
+// expire 항목의 만료 시간을 d 만큼 앞당깁니다
+func expire(c *Cache, key string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key].expires = c.entries[key].expires.Add(-d)
+}
+
+// waitStats 통계가 조건을 만족할 때까지 기다립니다
+func waitStats(t *testing.T, c *Cache, ok func(CacheStats) bool) CacheStats {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := c.Stats()
+		if ok(stats) {
+			return stats
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("통계가 바뀌지 않았습니다: %+v", stats)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+var (
+	ip1 = []net.IP{net.IPv4(10, 0, 0, 1)}
+	ip2 = []net.IP{net.IPv4(10, 0, 0, 2)}
+)
+
+func TestCacheHit(t *testing.T) {
+	c := &Cache{}
+	var calls int32
+
+	for i := 0; i < 3; i++ {
+		ips, e := c.get(context.Background(), "ip a.test.", fetcher(&calls, ip1, time.Minute, nil))
+		if e != nil || ipStrings(ips) != "10.0.0.1" {
+			t.Fatalf("%s (%v)", ipStrings(ips), e)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("조회 횟수 %d, 기대값 1", calls)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Refreshes != 1 || stats.Entries != 1 {
+		t.Errorf("%+v", stats)
+	}
+
+	// 만료되고 StaleTTL 도 지났다면 다시 조회해야 함
+	expire(c, "ip a.test.", time.Hour)
+
+	ips, _ := c.get(context.Background(), "ip a.test.", fetcher(&calls, ip2, time.Minute, nil))
+	if ipStrings(ips) != "10.0.0.2" || calls != 2 {
+		t.Errorf("%s, 조회 횟수 %d", ipStrings(ips), calls)
+	}
+
+	c.Flush()
+
+	if c.get(context.Background(), "ip a.test.", fetcher(&calls, ip1, time.Minute, nil)); calls != 3 {
+		t.Errorf("비운 뒤 조회 횟수 %d, 기대값 3", calls)
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	c := &Cache{StaleTTL: time.Minute}
+	var calls int32
+
+	c.get(context.Background(), "ip a.test.", fetcher(&calls, ip1, time.Second, nil))
+	expire(c, "ip a.test.", 2*time.Second)
+
+	// 다시 조회하는 동안 이전 결과를 바로 받아야 함
+	release := make(chan struct{})
+	slow := func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		<-release
+		atomic.AddInt32(&calls, 1)
+		return ip2, time.Minute, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		ips, e := c.get(context.Background(), "ip a.test.", slow)
+		if e != nil || ipStrings(ips) != "10.0.0.1" {
+			t.Fatalf("%s (%v)", ipStrings(ips), e)
+		}
+	}
+
+	close(release)
+
+	// 갱신은 한 번만 해야 함
+	stats := waitStats(t, c, func(s CacheStats) bool { return s.Refreshes == 2 })
+	if stats.StaleHits != 3 || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("%+v, 조회 횟수 %d", stats, atomic.LoadInt32(&calls))
+	}
+
+	if ips, _ := c.get(context.Background(), "ip a.test.", slow); ipStrings(ips) != "10.0.0.2" {
+		t.Errorf("갱신한 결과 %s, 기대값 10.0.0.2", ipStrings(ips))
+	}
+
+	// 갱신에 실패해도 이전 결과를 계속 사용해야 함
+	expire(c, "ip a.test.", 2*time.Minute-time.Second)
+
+	var failed int32
+	fail := fetcher(&failed, nil, 0, errors.New("SERVFAIL"))
+
+	if ips, e := c.get(context.Background(), "ip a.test.", fail); e != nil || ipStrings(ips) != "10.0.0.2" {
+		t.Fatalf("%s (%v)", ipStrings(ips), e)
+	}
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Errors == 1 })
+
+	if ips, e := c.get(context.Background(), "ip a.test.", fail); e != nil || ipStrings(ips) != "10.0.0.2" {
+		t.Errorf("%s (%v)", ipStrings(ips), e)
+	}
+
+	// 실패한 뒤에도 다시 갱신을 시도해야 함
+	waitStats(t, c, func(s CacheStats) bool { return s.Errors == 2 })
+}
+
+func TestCacheNegative(t *testing.T) {
+	c := &Cache{StaleTTL: time.Minute, NegativeTTL: time.Second}
+	var calls int32
+	fail := errors.New("NXDOMAIN")
+
+	for i := 0; i < 3; i++ {
+		if _, e := c.get(context.Background(), "ip x.test.", fetcher(&calls, nil, 0, fail)); e != fail {
+			t.Fatalf("%v, 기대값 %v", e, fail)
+		}
+	}
+
+	stats := c.Stats()
+	if calls != 1 || stats.NegativeHits != 2 || stats.Errors != 1 {
+		t.Errorf("%+v, 조회 횟수 %d", stats, calls)
+	}
+
+	// 실패한 결과는 만료된 뒤 이전 결과로 사용하지 않고 다시 조회해야 함
+	expire(c, "ip x.test.", 2*time.Second)
+
+	ips, e := c.get(context.Background(), "ip x.test.", fetcher(&calls, ip1, time.Minute, nil))
+	if e != nil || ipStrings(ips) != "10.0.0.1" || calls != 2 {
+		t.Errorf("%s (%v), 조회 횟수 %d", ipStrings(ips), e, calls)
+	}
+
+	// NegativeTTL 이 없다면 실패를 기억하지 않아야 함
+	c = &Cache{}
+	calls = 0
+
+	for i := 0; i < 2; i++ {
+		c.get(context.Background(), "ip x.test.", fetcher(&calls, nil, 0, fail))
+	}
+
+	if calls != 2 || c.Stats().Entries != 0 {
+		t.Errorf("조회 횟수 %d, 항목 %d", calls, c.Stats().Entries)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c := &Cache{}
+	var calls int32
+	release := make(chan struct{})
+
+	slow := func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return ip1, time.Minute, nil
+	}
+
+	const waiters = 20
+
+	var wg sync.WaitGroup
+	results := make([]string, waiters)
+
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ips, e := c.get(context.Background(), "ip a.test.", slow)
+			if e != nil {
+				t.Error(e)
+			}
+
+			results[i] = ipStrings(ips)
+		}(i)
+	}
+
+	// 모두 같은 조회를 기다릴 때까지 기다리기
+	waitStats(t, c, func(s CacheStats) bool { return s.Misses+s.Shared == waiters })
+	close(release)
+	wg.Wait()
+
+	for i, result := range results {
+		if result != "10.0.0.1" {
+			t.Errorf("%d: %s", i, result)
+		}
+	}
+
+	stats := c.Stats()
+	if atomic.LoadInt32(&calls) != 1 || stats.Misses != 1 || stats.Shared != waiters-1 {
+		t.Errorf("%+v, 조회 횟수 %d", stats, atomic.LoadInt32(&calls))
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	c := &Cache{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	first := make(chan error)
+	go func() {
+		_, e := c.get(context.Background(), "ip a.test.", func(ctx context.Context) ([]net.IP, time.Duration, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+
+		first <- e
+	}()
+
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		_, e := c.get(context.Background(), "ip a.test.", fetcher(new(int32), ip1, time.Minute, nil))
+		waited <- e
+	}()
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Shared == 1 })
+	close(release)
+
+	// 조회는 백그라운드에서 하므로 패닉은 프로세스를 멈추지 않고 모든 요청에 오류로 전달돼야 함
+	for _, result := range []chan error{first, waited} {
+		select {
+		case e := <-result:
+			if e != errFetchPanic {
+				t.Errorf("%v, 기대값 %v", e, errFetchPanic)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("기다리던 요청이 끝나지 않았습니다")
+		}
+	}
+
+	// 패닉이 발생한 조회는 남아있지 않아야 함
+	var calls int32
+	if ips, e := c.get(context.Background(), "ip a.test.", fetcher(&calls, ip1, time.Minute, nil)); e != nil || ipStrings(ips) != "10.0.0.1" || calls != 1 {
+		t.Errorf("%s (%v), 조회 횟수 %d", ipStrings(ips), e, calls)
+	}
+}
+
+func TestCacheRefreshPanic(t *testing.T) {
+	c := &Cache{StaleTTL: time.Minute}
+
+	if _, e := c.get(context.Background(), "ip a.test.", fetcher(new(int32), ip1, time.Minute, nil)); e != nil {
+		t.Fatal(e)
+	}
+
+	expire(c, "ip a.test.", 90*time.Second)
+
+	// 백그라운드 갱신에서 패닉이 발생해도 프로세스가 멈추지 않고 이전 결과를 계속 사용해야 함
+	ips, e := c.get(context.Background(), "ip a.test.", func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		panic("boom")
+	})
+
+	if e != nil || ipStrings(ips) != "10.0.0.1" {
+		t.Fatalf("%s (%v)", ipStrings(ips), e)
+	}
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Errors == 1 })
+
+	// 다시 갱신할 수 있어야 함
+	var calls int32
+	c.get(context.Background(), "ip a.test.", fetcher(&calls, ip2, time.Minute, nil))
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Refreshes == 2 })
+
+	if ips, _ := c.get(context.Background(), "ip a.test.", nil); ipStrings(ips) != "10.0.0.2" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("%s, 조회 횟수 %d", ipStrings(ips), atomic.LoadInt32(&calls))
+	}
+}
+
+func TestCacheCancel(t *testing.T) {
+	c := &Cache{NegativeTTL: time.Minute}
+	var calls int32
+	release := make(chan struct{})
+
+	slow := func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+
+		select {
+		case <-release:
+			return ip1, time.Minute, nil
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first := make(chan error)
+	go func() {
+		_, e := c.get(ctx, "ip a.test.", slow)
+		first <- e
+	}()
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Misses == 1 })
+
+	second := make(chan []net.IP)
+	go func() {
+		ips, e := c.get(context.Background(), "ip a.test.", slow)
+		if e != nil {
+			t.Error(e)
+		}
+
+		second <- ips
+	}()
+
+	waitStats(t, c, func(s CacheStats) bool { return s.Shared == 1 })
+
+	// 처음 요청한 클라이언트가 취소해도 다른 요청은 결과를 받아야 함
+	cancel()
+
+	if e := <-first; e != context.Canceled {
+		t.Errorf("%v, 기대값 %v", e, context.Canceled)
+	}
+
+	close(release)
+
+	if ips := <-second; ipStrings(ips) != "10.0.0.1" {
+		t.Errorf("%s, 기대값 10.0.0.1", ipStrings(ips))
+	}
+
+	if ips, e := c.get(context.Background(), "ip a.test.", slow); e != nil || ipStrings(ips) != "10.0.0.1" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("%s (%v), 조회 횟수 %d", ipStrings(ips), e, atomic.LoadInt32(&calls))
+	}
+}
+
+func TestCacheContextError(t *testing.T) {
+	c := &Cache{NegativeTTL: time.Minute}
+
+	tests := []struct {
+		err      error
+		negative bool
+	}{
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{fmt.Errorf("DNS 서버에 질의할 수 없습니다: %w", context.DeadlineExceeded), false},
+		{errors.New("SERVFAIL"), true},
+	}
+
+	for i, test := range tests {
+		key := fmt.Sprintf("ip %d.test.", i)
+
+		c.get(context.Background(), key, fetcher(new(int32), nil, 0, test.err))
+
+		// 제한 시간이 지나거나 취소된 조회는 기억하지 않아야 함
+		var calls int32
+		c.get(context.Background(), key, fetcher(&calls, ip1, time.Minute, nil))
+
+		if negative := calls == 0; negative != test.negative {
+			t.Errorf("%v: 실패 기억 %v, 기대값 %v", test.err, negative, test.negative)
+		}
+	}
+}
+
+func TestCacheRace(t *testing.T) {
+	c := &Cache{StaleTTL: 50 * time.Millisecond, NegativeTTL: 10 * time.Millisecond}
+	keys := []string{"ip a.test.", "ip b.test.", "ip c.test."}
+
+	var calls int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 200; j++ {
+				key := keys[j%len(keys)]
+
+				var fail error
+				if key == "ip c.test." {
+					fail = errors.New("SERVFAIL")
+				}
+
+				ips, e := c.get(context.Background(), key, fetcher(&calls, ip1, time.Millisecond, fail))
+				if (e == nil) != (fail == nil) || (e == nil && ipStrings(ips) != "10.0.0.1") {
+					t.Errorf("%s: %s (%v)", key, ipStrings(ips), e)
+				}
+
+				switch {
+				case j%50 == 0:
+					c.Stats()
+					c.Entries()
+				case i == 0 && j == 100:
+					c.Flush()
+				}
+
+				time.Sleep(10 * time.Microsecond)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	stats := c.Stats()
+	if lookups := stats.Hits + stats.StaleHits + stats.NegativeHits + stats.Misses + stats.Shared; lookups != 50*200 {
+		t.Errorf("%+v, 요청 %d, 기대값 %d", stats, lookups, 50*200)
+	}
+
+	// 백그라운드 갱신이 끝날 때까지 기다리기
+	waitStats(t, c, func(s CacheStats) bool { return s.Refreshes == uint64(atomic.LoadInt32(&calls)) })
+}
@@ -0,0 +1,401 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNoAnswer 응답에 요청한 주소 레코드가 없을 때 반환됩니다
+var ErrNoAnswer = errors.New("DNS 응답에 주소 레코드가 없습니다")
+
+// ErrCNAMELoop CNAME 레코드가 너무 깊거나 순환할 때 반환됩니다
+var ErrCNAMELoop = errors.New("CNAME 레코드가 너무 깊거나 순환합니다")
+
+// RcodeError DNS 서버가 실패 응답 코드를 보냈을 때 반환됩니다
+type RcodeError struct {
+	Name  string
+	Rcode int
+}
+
+func (e *RcodeError) Error() string {
+	return fmt.Sprintf("%s 조회에 실패했습니다: %s", e.Name, dns.RcodeToString[e.Rcode])
+}
+
+// CNAME 을 따라갈 최대 깊이
+var maxCNAMEDepth = 8
+
+var defaultTimeout = 5 * time.Second
+
+// DefaultStaleTTL New 로 만든 리졸버가 만료된 결과를 다시 조회하는 동안 사용할 수 있는 시간
+var DefaultStaleTTL = time.Minute
+
+// DefaultNegativeTTL New 로 만든 리졸버가 조회 실패를 기억할 시간
+var DefaultNegativeTTL = 5 * time.Second
+
+// Upstream 질의를 보낼 DNS 서버
+type Upstream struct {
+	// udp, tcp, tls (DNS-over-TLS), https (DNS-over-HTTPS)
+	Protocol string
+	// 호스트:포트 또는 DNS-over-HTTPS 주소
+	Address string
+}
+
+func (u Upstream) String() string {
+	if u.Protocol == "https" {
+		return u.Address
+	}
+
+	return u.Protocol + "://" + u.Address
+}
+
+// ParseUpstream 문자열을 업스트림 서버로 변환합니다
+// 예) 1.1.1.1, tcp://1.1.1.1:53, tls://1.1.1.1:853, https://cloudflare-dns.com/dns-query
+func ParseUpstream(s string) (Upstream, error) {
+	s = strings.TrimSpace(s)
+	u := Upstream{Protocol: "udp", Address: s}
+
+	if i := strings.Index(s, "://"); i >= 0 {
+		u.Protocol = s[:i]
+		u.Address = s[i+3:]
+	}
+
+	var port string
+
+	switch u.Protocol {
+	case "udp", "tcp":
+		port = "53"
+	case "tls":
+		port = "853"
+	case "https":
+		if _, e := url.Parse(s); e != nil {
+			return u, e
+		}
+
+		u.Address = s
+		return u, nil
+	default:
+		return u, fmt.Errorf("%s 값은 지원하지 않는 DNS 프로토콜입니다", u.Protocol)
+	}
+
+	if u.Address == "" {
+		return u, fmt.Errorf("%s 값에 DNS 서버 주소가 없습니다", s)
+	}
+
+	// 포트가 없다면 기본 포트 붙이기
+	if _, _, e := net.SplitHostPort(u.Address); e != nil {
+		u.Address = net.JoinHostPort(strings.Trim(u.Address, "[]"), port)
+	}
+
+	return u, nil
+}
+
+// Resolver 지정한 업스트림 DNS 서버로 주소를 조회합니다
+// 시스템 DNS 설정이나 호스트 파일을 거치지 않습니다
+type Resolver struct {
+	Upstreams []Upstream
+	// ip (A, AAAA 모두), ip4 (A), ip6 (AAAA)
+	Network string
+	// 업스트림 서버 하나당 요청 제한 시간
+	Timeout time.Duration
+	// 조회 결과 캐시
+	Cache Cache
+}
+
+// New 업스트림 서버 주소 목록으로 리졸버를 만듭니다
+func New(upstreams []string, network string) (*Resolver, error) {
+	r := &Resolver{Network: network}
+	r.Cache.StaleTTL = DefaultStaleTTL
+	r.Cache.NegativeTTL = DefaultNegativeTTL
+
+	switch network {
+	case "", "ip", "ip4", "ip6":
+	default:
+		return nil, fmt.Errorf("%s 값은 사용할 수 있는 네트워크 종류가 아닙니다", network)
+	}
+
+	for _, s := range upstreams {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		u, e := ParseUpstream(s)
+		if e != nil {
+			return nil, e
+		}
+
+		r.Upstreams = append(r.Upstreams, u)
+	}
+
+	if len(r.Upstreams) == 0 {
+		return nil, errors.New("DNS 서버가 하나 이상 필요합니다")
+	}
+
+	return r, nil
+}
+
+func (r *Resolver) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+
+	return defaultTimeout
+}
+
+func (r *Resolver) qtypes() []uint16 {
+	switch r.Network {
+	case "ip4":
+		return []uint16{dns.TypeA}
+	case "ip6":
+		return []uint16{dns.TypeAAAA}
+	default:
+		return []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+}
+
+// LookupIP 호스트의 주소를 조회합니다
+// 응답의 TTL 만큼 결과를 캐시하며, A 레코드가 AAAA 레코드보다 먼저 옵니다
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	name := dns.Fqdn(host)
+
+	// 캐시 키는 "네트워크 이름" 형태
+	key := r.Network + " " + strings.ToLower(name)
+
+	return r.Cache.get(ctx, key, func(ctx context.Context) ([]net.IP, time.Duration, error) {
+		// 캐시는 요청과 상관없는 컨텍스트로 조회하므로 모든 업스트림 서버를 시도할 시간만큼만 기다리기
+		ctx, cancel := context.WithTimeout(ctx, r.timeout()*time.Duration(len(r.Upstreams)*len(r.qtypes())))
+		defer cancel()
+
+		var ips []net.IP
+		var firstError error
+		ttl := uint32(math.MaxUint32)
+
+		for _, qtype := range r.qtypes() {
+			found, t, e := r.lookup(ctx, name, qtype)
+			if e != nil {
+				if firstError == nil {
+					firstError = e
+				}
+
+				continue
+			}
+
+			ips = append(ips, found...)
+			ttl = minTTL(ttl, t)
+		}
+
+		if len(ips) == 0 {
+			return nil, 0, firstError
+		}
+
+		return ips, time.Duration(ttl) * time.Second, nil
+	})
+}
+
+func (r *Resolver) lookup(ctx context.Context, name string, qtype uint16) ([]net.IP, uint32, error) {
+	target := name
+	ttl := uint32(math.MaxUint32)
+
+	for depth := 0; depth < maxCNAMEDepth; depth++ {
+		m := new(dns.Msg)
+		m.SetQuestion(target, qtype)
+
+		res, e := r.Exchange(ctx, m)
+		if e != nil {
+			return nil, 0, e
+		}
+
+		if res.Rcode != dns.RcodeSuccess {
+			return nil, 0, &RcodeError{Name: target, Rcode: res.Rcode}
+		}
+
+		ips, next, t, e := extract(res.Answer, target, qtype)
+		if e != nil {
+			return nil, 0, e
+		}
+
+		ttl = minTTL(ttl, t)
+
+		if len(ips) > 0 {
+			return ips, ttl, nil
+		}
+
+		// 응답에 CNAME 만 있다면 대상 이름으로 다시 조회하기
+		if strings.EqualFold(next, target) {
+			return nil, 0, ErrNoAnswer
+		}
+
+		target = next
+	}
+
+	return nil, 0, ErrCNAMELoop
+}
+
+// extract 응답 안의 CNAME 체인을 따라간 뒤 최종 이름의 주소를 꺼냅니다
+// 체인이 순환하거나 너무 깊다면 ErrCNAMELoop 를 반환합니다
+func extract(answer []dns.RR, name string, qtype uint16) ([]net.IP, string, uint32, error) {
+	var ips []net.IP
+	target := name
+	ttl := uint32(math.MaxUint32)
+	seen := map[string]bool{strings.ToLower(target): true}
+
+	for {
+		next := ""
+
+		for _, rr := range answer {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, target) {
+				next = c.Target
+				ttl = minTTL(ttl, c.Hdr.Ttl)
+				break
+			}
+		}
+
+		if next == "" {
+			break
+		}
+
+		if seen[strings.ToLower(next)] || len(seen) > maxCNAMEDepth {
+			return nil, "", 0, ErrCNAMELoop
+		}
+
+		seen[strings.ToLower(next)] = true
+		target = next
+	}
+
+	for _, rr := range answer {
+		if !strings.EqualFold(rr.Header().Name, target) {
+			continue
+		}
+
+		switch v := rr.(type) {
+		case *dns.A:
+			if qtype == dns.TypeA {
+				ips = append(ips, v.A)
+				ttl = minTTL(ttl, v.Hdr.Ttl)
+			}
+		case *dns.AAAA:
+			if qtype == dns.TypeAAAA {
+				ips = append(ips, v.AAAA)
+				ttl = minTTL(ttl, v.Hdr.Ttl)
+			}
+		}
+	}
+
+	return ips, target, ttl, nil
+}
+
+// Exchange 업스트림 서버에 순서대로 메세지를 보내고 처음으로 성공한 응답을 반환합니다
+func (r *Resolver) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	var errs []string
+
+	for _, u := range r.Upstreams {
+		res, e := r.exchange(ctx, u, m)
+		if e == nil && (res.Rcode == dns.RcodeServerFailure || res.Rcode == dns.RcodeRefused) {
+			e = errors.New(dns.RcodeToString[res.Rcode])
+		}
+
+		if e == nil {
+			return res, nil
+		}
+
+		errs = append(errs, fmt.Sprintf("%s (%s)", u, e))
+
+		// 취소됐다면 캐시가 실패로 기억하지 않도록 컨텍스트 오류를 함께 반환하기
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("DNS 서버에 질의할 수 없습니다: %s: %w", strings.Join(errs, ", "), ctx.Err())
+		}
+	}
+
+	return nil, fmt.Errorf("DNS 서버에 질의할 수 없습니다: %s", strings.Join(errs, ", "))
+}
+
+func (r *Resolver) exchange(ctx context.Context, u Upstream, m *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	switch u.Protocol {
+	case "https":
+		return r.exchangeHTTPS(ctx, u.Address, m)
+	case "tls":
+		host, _, _ := net.SplitHostPort(u.Address)
+		c := dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{ServerName: host}}
+		res, _, e := c.ExchangeContext(ctx, m, u.Address)
+		return res, e
+	default:
+		c := dns.Client{Net: u.Protocol}
+		res, _, e := c.ExchangeContext(ctx, m, u.Address)
+
+		// UDP 응답이 잘렸다면 TCP 로 다시 요청하기
+		if e == nil && res.Truncated && u.Protocol == "udp" {
+			c.Net = "tcp"
+			res, _, e = c.ExchangeContext(ctx, m, u.Address)
+		}
+
+		return res, e
+	}
+}
+
+// exchangeHTTPS RFC 8484 DNS-over-HTTPS 로 질의합니다
+func (r *Resolver) exchangeHTTPS(ctx context.Context, endpoint string, m *dns.Msg) (*dns.Msg, error) {
+	// 캐시 효율을 위해 아이디는 0 으로 보내야함
+	query := m.Copy()
+	query.Id = 0
+
+	data, e := query.Pack()
+	if e != nil {
+		return nil, e
+	}
+
+	req, e := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if e != nil {
+		return nil, e
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	res, e := http.DefaultClient.Do(req)
+	if e != nil {
+		return nil, e
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS 서버가 %s 상태를 응답했습니다", res.Status)
+	}
+
+	body, e := ioutil.ReadAll(res.Body)
+	if e != nil {
+		return nil, e
+	}
+
+	answer := new(dns.Msg)
+	if e := answer.Unpack(body); e != nil {
+		return nil, e
+	}
+
+	answer.Id = m.Id
+
+	return answer, nil
+}
+
+func minTTL(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
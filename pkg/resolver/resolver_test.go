@@ -0,0 +1,431 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// listenDNS 같은 포트의 UDP, TCP 소켓을 엽니다
+func listenDNS(t *testing.T) (net.PacketConn, net.Listener) {
+	t.Helper()
+
+	var pc net.PacketConn
+	var l net.Listener
+
+	// UDP 로 받은 포트가 TCP 에서 사용 중일 수 있으니 몇 번 시도하기
+	for i := 0; i < 10 && l == nil; i++ {
+		var e error
+
+		pc, e = net.ListenPacket("udp", "127.0.0.1:0")
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		l, e = net.Listen("tcp", pc.LocalAddr().String())
+		if e != nil {
+			pc.Close()
+			l = nil
+		}
+	}
+
+	if l == nil {
+		t.Fatal("DNS 서버가 사용할 포트를 찾을 수 없습니다")
+	}
+
+	return pc, l
+}
+
+// startServer 같은 포트의 UDP, TCP 로 질의를 받는 DNS 서버를 시작하고 주소를 반환합니다
+func startServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+
+	pc, l := listenDNS(t)
+
+	udp := &dns.Server{PacketConn: pc, Handler: handler}
+	tcp := &dns.Server{Listener: l, Handler: handler}
+
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+
+	t.Cleanup(func() {
+		udp.Shutdown()
+		tcp.Shutdown()
+	})
+
+	return pc.LocalAddr().String()
+}
+
+// zone 질문의 이름과 같은 이름의 레코드로 응답하는 핸들러를 만듭니다, 레코드가 없는 이름은 NXDOMAIN 으로 응답합니다
+func zone(t *testing.T, records ...string) dns.HandlerFunc {
+	t.Helper()
+
+	var rrs []dns.RR
+	for _, s := range records {
+		rr, e := dns.NewRR(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		rrs = append(rrs, rr)
+	}
+
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Rcode = dns.RcodeNameError
+
+		q := r.Question[0]
+		for _, rr := range rrs {
+			if !strings.EqualFold(rr.Header().Name, q.Name) {
+				continue
+			}
+
+			m.Rcode = dns.RcodeSuccess
+			if rr.Header().Rrtype == q.Qtype || rr.Header().Rrtype == dns.TypeCNAME {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+
+		w.WriteMsg(m)
+	}
+}
+
+// static 질문과 관계없이 모든 레코드로 응답하는 핸들러를 만듭니다
+func static(t *testing.T, records ...string) dns.HandlerFunc {
+	t.Helper()
+
+	var rrs []dns.RR
+	for _, s := range records {
+		rr, e := dns.NewRR(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		rrs = append(rrs, rr)
+	}
+
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = rrs
+		w.WriteMsg(m)
+	}
+}
+
+func newResolver(t *testing.T, network string, upstreams ...string) *Resolver {
+	t.Helper()
+
+	r, e := New(upstreams, network)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r.Timeout = time.Second
+
+	return r
+}
+
+func ipStrings(ips []net.IP) string {
+	var s []string
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+
+	return strings.Join(s, ",")
+}
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"1.1.1.1", "udp://1.1.1.1:53", false},
+		{"tcp://1.1.1.1", "tcp://1.1.1.1:53", false},
+		{"tcp://1.1.1.1:5353", "tcp://1.1.1.1:5353", false},
+		{"tls://1.1.1.1", "tls://1.1.1.1:853", false},
+		{"2606:4700::1111", "udp://[2606:4700::1111]:53", false},
+		{"[2606:4700::1111]:5353", "udp://[2606:4700::1111]:5353", false},
+		{"https://cloudflare-dns.com/dns-query", "https://cloudflare-dns.com/dns-query", false},
+		{"ftp://1.1.1.1", "", true},
+		{"tcp://", "", true},
+	}
+
+	for _, test := range tests {
+		u, e := ParseUpstream(test.in)
+		if test.err {
+			if e == nil {
+				t.Errorf("%s: 오류가 없습니다", test.in)
+			}
+
+			continue
+		}
+
+		if e != nil || u.String() != test.want {
+			t.Errorf("%s: %s (%v), 기대값 %s", test.in, u, e, test.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, e := New(nil, "ip"); e == nil {
+		t.Error("업스트림 없이 만들어졌습니다")
+	}
+
+	if _, e := New([]string{"1.1.1.1"}, "tcp"); e == nil {
+		t.Error("잘못된 네트워크로 만들어졌습니다")
+	}
+}
+
+func TestLookupCNAME(t *testing.T) {
+	// 한 응답 안에 체인 전체가 있는 서버
+	within := startServer(t, static(t,
+		"other.test. 60 IN A 10.0.0.9",
+		"three.test. 60 IN A 10.0.0.1",
+		"two.test. 60 IN CNAME three.test.",
+		"one.test. 60 IN CNAME two.test.",
+	))
+
+	// 응답마다 CNAME 하나만 있는 서버
+	across := startServer(t, zone(t,
+		"across.test. 60 IN CNAME middle.test.",
+		"middle.test. 60 IN CNAME end.test.",
+		"end.test. 60 IN A 10.0.0.2",
+	))
+
+	tests := []struct {
+		addr string
+		host string
+		want string
+	}{
+		{within, "one.test", "10.0.0.1"},
+		{within, "ONE.test.", "10.0.0.1"},
+		{across, "across.test", "10.0.0.2"},
+	}
+
+	for _, test := range tests {
+		r := newResolver(t, "ip4", test.addr)
+
+		ips, e := r.LookupIP(context.Background(), test.host)
+		if e != nil || ipStrings(ips) != test.want {
+			t.Errorf("%s: %s (%v), 기대값 %s", test.host, ipStrings(ips), e, test.want)
+		}
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	addr := startServer(t, zone(t,
+		"self.test. 60 IN CNAME self.test.",
+		"empty.test. 60 IN TXT \"no address\"",
+		"dangling.test. 60 IN CNAME empty.test.",
+	))
+
+	// 한 응답 안에서 순환하는 서버
+	loop := startServer(t, static(t,
+		"ping.test. 60 IN CNAME pong.test.",
+		"pong.test. 60 IN CNAME ping.test.",
+	))
+
+	// 응답마다 다른 이름의 CNAME 하나만 보내며 끝나지 않는 서버
+	var hops int32
+	chain := startServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		n := atomic.AddInt32(&hops, 1)
+
+		rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN CNAME hop%d.test.", r.Question[0].Name, n))
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{rr}
+		w.WriteMsg(m)
+	})
+
+	tests := []struct {
+		addr string
+		host string
+		want error
+	}{
+		{addr, "self.test", ErrCNAMELoop},
+		{loop, "ping.test", ErrCNAMELoop},
+		{chain, "start.test", ErrCNAMELoop},
+		{addr, "empty.test", ErrNoAnswer},
+		{addr, "dangling.test", ErrNoAnswer},
+	}
+
+	for _, test := range tests {
+		r := newResolver(t, "ip4", test.addr)
+
+		if _, e := r.LookupIP(context.Background(), test.host); e != test.want {
+			t.Errorf("%s: %v, 기대값 %v", test.host, e, test.want)
+		}
+	}
+
+	if n := atomic.LoadInt32(&hops); n != int32(maxCNAMEDepth) {
+		t.Errorf("CNAME 을 %d번 따라갔습니다, 기대값 %d", n, maxCNAMEDepth)
+	}
+
+	r := newResolver(t, "ip4", addr)
+
+	_, e := r.LookupIP(context.Background(), "missing.test")
+
+	var rcode *RcodeError
+	if !errors.As(e, &rcode) || rcode.Rcode != dns.RcodeNameError || rcode.Name != "missing.test." {
+		t.Errorf("missing.test: %v, 기대값 NXDOMAIN", e)
+	}
+}
+
+func TestLookupNetwork(t *testing.T) {
+	addr := startServer(t, zone(t,
+		"both.test. 60 IN A 10.0.0.1",
+		"both.test. 60 IN AAAA fd00::1",
+		"v4.test. 60 IN A 10.0.0.2",
+		"v6.test. 60 IN AAAA fd00::2",
+	))
+
+	tests := []struct {
+		network string
+		host    string
+		want    string
+		err     error
+	}{
+		{"ip", "both.test", "10.0.0.1,fd00::1", nil},
+		{"", "both.test", "10.0.0.1,fd00::1", nil},
+		{"ip4", "both.test", "10.0.0.1", nil},
+		{"ip6", "both.test", "fd00::1", nil},
+		{"ip", "v4.test", "10.0.0.2", nil},
+		{"ip", "v6.test", "fd00::2", nil},
+		{"ip4", "v6.test", "", ErrNoAnswer},
+		{"ip6", "v4.test", "", ErrNoAnswer},
+	}
+
+	for _, test := range tests {
+		r := newResolver(t, test.network, addr)
+
+		ips, e := r.LookupIP(context.Background(), test.host)
+		if e != test.err || ipStrings(ips) != test.want {
+			t.Errorf("%s %s: %s (%v), 기대값 %s (%v)", test.network, test.host, ipStrings(ips), e, test.want, test.err)
+		}
+	}
+}
+
+func TestLookupTTL(t *testing.T) {
+	addr := startServer(t, zone(t,
+		"alias.test. 30 IN CNAME target.test.",
+		"target.test. 300 IN A 10.0.0.1",
+		"target.test. 120 IN A 10.0.0.2",
+		"target.test. 600 IN AAAA fd00::1",
+	))
+
+	r := newResolver(t, "ip", addr)
+
+	if _, e := r.LookupIP(context.Background(), "target.test"); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := r.LookupIP(context.Background(), "alias.test"); e != nil {
+		t.Fatal(e)
+	}
+
+	// 레코드 중 가장 짧은 TTL 동안 캐시되어야 함
+	want := map[string]time.Duration{
+		"target.test.": 120 * time.Second,
+		"alias.test.":  30 * time.Second,
+	}
+
+	for _, entry := range r.Cache.Entries() {
+		ttl := time.Until(entry.Expires)
+		if ttl > want[entry.Host] || ttl < want[entry.Host]-5*time.Second {
+			t.Errorf("%s: TTL %s, 기대값 %s", entry.Host, ttl, want[entry.Host])
+		}
+	}
+}
+
+func TestExchangeFallback(t *testing.T) {
+	var failed, refused, answered int32
+
+	fail := startServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(&failed, 1)
+
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+
+	refuse := startServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(&refused, 1)
+
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+	})
+
+	good := zone(t, "nmsg.test. 60 IN A 10.0.0.1")
+	answer := startServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(&answered, 1)
+		good(w, r)
+	})
+
+	r := newResolver(t, "ip4", fail, refuse, answer)
+
+	ips, e := r.LookupIP(context.Background(), "nmsg.test")
+	if e != nil || ipStrings(ips) != "10.0.0.1" {
+		t.Fatalf("%s (%v)", ipStrings(ips), e)
+	}
+
+	if f, r, a := atomic.LoadInt32(&failed), atomic.LoadInt32(&refused), atomic.LoadInt32(&answered); f != 1 || r != 1 || a != 1 {
+		t.Errorf("질의 횟수 %d, %d, %d, 기대값 1, 1, 1", f, r, a)
+	}
+
+	// NXDOMAIN 은 실패가 아니므로 다음 서버로 넘어가지 않아야 함
+	r = newResolver(t, "ip4", answer, fail)
+
+	if _, e := r.LookupIP(context.Background(), "missing.test"); e == nil || atomic.LoadInt32(&failed) != 1 {
+		t.Errorf("%v, 실패한 서버 질의 횟수 %d", e, atomic.LoadInt32(&failed))
+	}
+
+	// 모든 서버가 실패하면 각 서버의 오류를 알려줘야 함
+	r = newResolver(t, "ip4", fail, refuse)
+
+	_, e = r.LookupIP(context.Background(), "nmsg.test")
+	if e == nil || !strings.Contains(e.Error(), "SERVFAIL") || !strings.Contains(e.Error(), "REFUSED") {
+		t.Errorf("%v", e)
+	}
+}
+
+func TestExchangeTruncated(t *testing.T) {
+	var udp, tcp int32
+
+	good := zone(t, "big.test. 60 IN A 10.0.0.1")
+	addr := startServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			atomic.AddInt32(&tcp, 1)
+			good(w, r)
+			return
+		}
+
+		atomic.AddInt32(&udp, 1)
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		w.WriteMsg(m)
+	})
+
+	r := newResolver(t, "ip4", addr)
+
+	ips, e := r.LookupIP(context.Background(), "big.test")
+	if e != nil || ipStrings(ips) != "10.0.0.1" {
+		t.Fatalf("%s (%v)", ipStrings(ips), e)
+	}
+
+	if u, c := atomic.LoadInt32(&udp), atomic.LoadInt32(&tcp); u != 1 || c != 1 {
+		t.Errorf("UDP 질의 %d, TCP 질의 %d, 기대값 1, 1", u, c)
+	}
+}
@@ -0,0 +1,246 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Server 지정한 호스트는 직접 응답하고 나머지 질의는 업스트림으로 전달하는 DNS 서버
+// 호스트 파일을 수정할 수 없는 기기에서 DNS 서버를 니코트랜스로 지정해 사용합니다
+type Server struct {
+	// 대기할 주소 (예: :53)
+	Addr string
+	// 직접 응답할 호스트와 주소
+	Records map[string]net.IP
+	// 직접 응답하는 레코드의 TTL
+	TTL uint32
+	// 나머지 질의를 전달할 리졸버
+	Resolver *Resolver
+	// 질의할 수 있는 클라이언트 대역, 비어있으면 루프백과 사설 대역만 허용합니다
+	// 아무나 질의할 수 있는 공개 재귀 DNS 서버가 되지 않도록 나머지 클라이언트는 거부합니다
+	Clients []*net.IPNet
+
+	mu     sync.Mutex
+	udp    *dns.Server
+	tcp    *dns.Server
+	closed bool
+}
+
+// 클라이언트 대역을 지정하지 않았을 때 허용하는 사설 대역
+var privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+
+	return networks
+}
+
+// NewServer 호스트 목록을 모두 ip 로 응답하는 DNS 서버를 만듭니다
+func NewServer(addr string, hosts []string, ip net.IP, upstream *Resolver) *Server {
+	records := map[string]net.IP{}
+	for _, host := range hosts {
+		records[strings.ToLower(dns.Fqdn(host))] = ip
+	}
+
+	return &Server{
+		Addr:     addr,
+		Records:  records,
+		TTL:      60,
+		Resolver: upstream,
+	}
+}
+
+// ListenAndServe UDP 와 TCP 로 질의를 받기 시작합니다
+// 서버가 종료되거나 오류가 발생할 때까지 반환되지 않습니다
+func (s *Server) ListenAndServe() error {
+	pc, e := net.ListenPacket("udp", s.Addr)
+	if e != nil {
+		return e
+	}
+
+	l, e := net.Listen("tcp", s.Addr)
+	if e != nil {
+		pc.Close()
+		return e
+	}
+
+	return s.Serve(pc, l)
+}
+
+// Serve 미리 열어둔 UDP, TCP 소켓으로 질의를 받습니다
+// 서버가 종료되거나 오류가 발생할 때까지 반환되지 않으며, Shutdown 으로 종료했다면 nil 을 반환합니다
+func (s *Server) Serve(pc net.PacketConn, l net.Listener) error {
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+		pc.Close()
+		l.Close()
+
+		return nil
+	}
+
+	udp := &dns.Server{PacketConn: pc, Handler: s}
+	tcp := &dns.Server{Listener: l, Handler: s}
+	s.udp, s.tcp = udp, tcp
+
+	s.mu.Unlock()
+
+	errs := make(chan error, 2)
+
+	go func() {
+		errs <- udp.ActivateAndServe()
+	}()
+
+	go func() {
+		errs <- tcp.ActivateAndServe()
+	}()
+
+	// 하나라도 멈추면 나머지도 멈추기
+	e := <-errs
+	stop(udp, tcp)
+	<-errs
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	return e
+}
+
+// Shutdown 서버를 종료합니다
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	udp, tcp := s.udp, s.tcp
+	s.mu.Unlock()
+
+	stop(udp, tcp)
+}
+
+// stop DNS 서버를 멈춥니다, 아직 시작하지 않았다면 소켓을 닫아 시작하자마자 멈추게 합니다
+func stop(servers ...*dns.Server) {
+	for _, srv := range servers {
+		if srv == nil || srv.Shutdown() == nil {
+			continue
+		}
+
+		if srv.PacketConn != nil {
+			srv.PacketConn.Close()
+		}
+
+		if srv.Listener != nil {
+			srv.Listener.Close()
+		}
+	}
+}
+
+// allowed 클라이언트가 질의할 수 있는지?
+func (s *Server) allowed(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+
+	networks := s.Clients
+	if len(networks) == 0 {
+		if ip.IsLoopback() {
+			return true
+		}
+
+		networks = privateNetworks
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ServeDNS 질의에 응답합니다
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if !s.allowed(w.RemoteAddr()) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	if len(r.Question) != 1 {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(m)
+		return
+	}
+
+	q := r.Question[0]
+
+	// 가로챌 호스트라면 직접 응답하기
+	if ip, ok := s.Records[strings.ToLower(q.Name)]; ok {
+		w.WriteMsg(s.answer(r, q, ip))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Resolver.timeout()*2)
+	defer cancel()
+
+	res, e := s.Resolver.Exchange(ctx, r)
+	if e != nil {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+
+	res.Id = r.Id
+
+	// UDP 라면 클라이언트가 받을 수 있는 크기로 자르기
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+
+		res.Truncate(size)
+	}
+
+	w.WriteMsg(res)
+}
+
+func (s *Server) answer(r *dns.Msg, q dns.Question, ip net.IP) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	hdr := dns.RR_Header{Name: q.Name, Class: dns.ClassINET, Ttl: s.TTL}
+
+	// 다른 종류의 질의에는 빈 응답을 보내서 실제 주소로 연결되지 않게 하기
+	switch {
+	case ip.To4() != nil && (q.Qtype == dns.TypeA || q.Qtype == dns.TypeANY):
+		hdr.Rrtype = dns.TypeA
+		m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip.To4()})
+	case ip.To4() == nil && (q.Qtype == dns.TypeAAAA || q.Qtype == dns.TypeANY):
+		hdr.Rrtype = dns.TypeAAAA
+		m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+	}
+
+	return m
+}
@@ -0,0 +1,113 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startNicoServer 니코트랜스 DNS 서버를 시작하고 주소를 반환합니다
+func startNicoServer(t *testing.T, upstream string, clients []*net.IPNet) string {
+	t.Helper()
+
+	s := NewServer("", []string{"nmsg.nicovideo.jp"}, net.ParseIP("192.168.0.2"), newResolver(t, "ip4", upstream))
+	s.Clients = clients
+
+	pc, l := listenDNS(t)
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(pc, l) }()
+
+	t.Cleanup(func() {
+		s.Shutdown()
+		<-served
+	})
+
+	return pc.LocalAddr().String()
+}
+
+func TestServer(t *testing.T) {
+	upstream := startServer(t, zone(t, "example.com. 300 IN A 93.184.216.34"))
+
+	tests := []struct {
+		name    string
+		clients []*net.IPNet
+		net     string
+		qname   string
+		qtype   uint16
+		rcode   int
+		answer  string
+	}{
+		{"가로챌 호스트", nil, "udp", "nmsg.nicovideo.jp.", dns.TypeA, dns.RcodeSuccess, "192.168.0.2"},
+		{"가로챌 호스트 대소문자", nil, "tcp", "NMSG.nicovideo.JP.", dns.TypeA, dns.RcodeSuccess, "192.168.0.2"},
+		{"가로챌 호스트의 다른 레코드", nil, "udp", "nmsg.nicovideo.jp.", dns.TypeAAAA, dns.RcodeSuccess, ""},
+		{"업스트림으로 전달", nil, "udp", "example.com.", dns.TypeA, dns.RcodeSuccess, "93.184.216.34"},
+		{"업스트림으로 전달 TCP", nil, "tcp", "example.com.", dns.TypeA, dns.RcodeSuccess, "93.184.216.34"},
+		{"업스트림의 NXDOMAIN", nil, "udp", "missing.example.com.", dns.TypeA, dns.RcodeNameError, ""},
+		{"허용한 대역", parseNetworks("127.0.0.0/8"), "udp", "example.com.", dns.TypeA, dns.RcodeSuccess, "93.184.216.34"},
+		{"허용하지 않은 클라이언트", parseNetworks("10.0.0.0/8"), "udp", "example.com.", dns.TypeA, dns.RcodeRefused, ""},
+		{"허용하지 않은 클라이언트의 가로챌 호스트", parseNetworks("10.0.0.0/8"), "tcp", "nmsg.nicovideo.jp.", dns.TypeA, dns.RcodeRefused, ""},
+	}
+
+	for _, test := range tests {
+		addr := startNicoServer(t, upstream, test.clients)
+
+		m := new(dns.Msg)
+		m.SetQuestion(test.qname, test.qtype)
+
+		c := dns.Client{Net: test.net, Timeout: 5 * time.Second}
+		res, _, e := c.Exchange(m, addr)
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+
+		if res.Rcode != test.rcode || res.Id != m.Id {
+			t.Errorf("%s: %s, 기대값 %s", test.name, dns.RcodeToString[res.Rcode], dns.RcodeToString[test.rcode])
+		}
+
+		var answer string
+		for _, rr := range res.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				answer = a.A.String()
+			}
+		}
+
+		if answer != test.answer || (test.answer == "" && len(res.Answer) > 0) {
+			t.Errorf("%s: %v, 기대값 %s", test.name, res.Answer, test.answer)
+		}
+	}
+}
+
+func TestServerAllowed(t *testing.T) {
+	tests := []struct {
+		clients []*net.IPNet
+		addr    net.Addr
+		want    bool
+	}{
+		{nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, true},
+		{nil, &net.TCPAddr{IP: net.ParseIP("::1")}, true},
+		{nil, &net.UDPAddr{IP: net.ParseIP("192.168.0.10")}, true},
+		{nil, &net.UDPAddr{IP: net.ParseIP("10.1.2.3")}, true},
+		{nil, &net.UDPAddr{IP: net.ParseIP("172.31.255.1")}, true},
+		{nil, &net.UDPAddr{IP: net.ParseIP("fd00::1")}, true},
+		{nil, &net.UDPAddr{IP: net.ParseIP("172.32.0.1")}, false},
+		{nil, &net.UDPAddr{IP: net.ParseIP("8.8.8.8")}, false},
+		{nil, &net.TCPAddr{IP: net.ParseIP("2001:db8::1")}, false},
+		{parseNetworks("192.168.0.0/24"), &net.UDPAddr{IP: net.ParseIP("192.168.0.10")}, true},
+		{parseNetworks("192.168.0.0/24"), &net.UDPAddr{IP: net.ParseIP("192.168.1.10")}, false},
+		{parseNetworks("192.168.0.0/24"), &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, false},
+		{parseNetworks("0.0.0.0/0"), &net.UDPAddr{IP: net.ParseIP("8.8.8.8")}, true},
+		{nil, &net.UnixAddr{Name: "/tmp/dns.sock"}, false},
+	}
+
+	for _, test := range tests {
+		s := &Server{Clients: test.clients}
+
+		if got := s.allowed(test.addr); got != test.want {
+			t.Errorf("%v %s: %v, 기대값 %v", test.clients, test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package system
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFdsStart systemd 가 전달하는 첫 번째 파일 디스크립터 번호
+const listenFdsStart = 3
+
+// InheritedFile 부모 프로세스에게 물려받은 소켓
+type InheritedFile struct {
+	// systemd 의 FileDescriptorName, 이름이 없다면 unknown
+	Name string
+	File *os.File
+}
+
+// InheritedFiles systemd 소켓 활성화 방식 (LISTEN_FDS, LISTEN_FDNAMES) 으로 물려받은 소켓을 반환합니다
+// 자식 프로세스가 다시 물려받지 않도록 환경 변수를 지우기 때문에 한 번만 호출해야 합니다
+func InheritedFiles() []InheritedFile {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	// LISTEN_PID 가 있다면 이 프로세스에게 전달된 것인지 확인하기
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+
+	n, e := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if e != nil || n <= 0 {
+		return nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make([]InheritedFile, n)
+	for i := range files {
+		fd := listenFdsStart + i
+		syscall.CloseOnExec(fd)
+
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		files[i] = InheritedFile{Name: name, File: os.NewFile(uintptr(fd), name)}
+	}
+
+	return files
+}
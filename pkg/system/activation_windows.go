@@ -0,0 +1,14 @@
+package system
+
+import "os"
+
+// InheritedFile 부모 프로세스에게 물려받은 소켓
+type InheritedFile struct {
+	Name string
+	File *os.File
+}
+
+// InheritedFiles 윈도우는 소켓 활성화를 지원하지 않습니다
+func InheritedFiles() []InheritedFile {
+	return nil
+}
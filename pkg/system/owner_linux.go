@@ -0,0 +1,53 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ChownTo 파일 소유자를 다른 사용자로 바꿉니다, 그룹이 비어있다면 사용자의 기본 그룹을 사용합니다
+func ChownTo(path string, username string, group string) error {
+	credential, e := lookupCredential(username, group)
+	if e != nil {
+		return e
+	}
+
+	return os.Chown(path, int(credential.Uid), int(credential.Gid))
+}
+
+// CheckWritable 다른 사용자가 폴더에 파일을 만들고 이름을 바꿀 수 있는지 확인합니다
+// StartAs 로 실행한 프로세스는 보조 그룹이 없으므로 소유자, 기본 그룹, 나머지 사용자 권한만 확인합니다
+func CheckWritable(dir string, username string, group string) error {
+	credential, e := lookupCredential(username, group)
+	if e != nil {
+		return e
+	}
+
+	info, e := os.Stat(dir)
+	if e != nil {
+		return e
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s 폴더의 소유자를 알 수 없습니다", dir)
+	}
+
+	// 파일을 만들려면 쓰기와 실행 권한이 모두 있어야 함
+	var need os.FileMode
+	switch {
+	case stat.Uid == credential.Uid:
+		need = 0300
+	case stat.Gid == credential.Gid:
+		need = 0030
+	default:
+		need = 0003
+	}
+
+	if !info.IsDir() || info.Mode().Perm()&need != need {
+		return fmt.Errorf("%s 사용자가 %s 폴더에 파일을 만들 수 없습니다", username, dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestCheckWritable(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("소유자를 바꾸려면 관리자 권한이 필요합니다")
+	}
+
+	nobody, e := user.Lookup("nobody")
+	if e != nil {
+		t.Skip(e)
+	}
+
+	uid, _ := strconv.Atoi(nobody.Uid)
+	gid, _ := strconv.Atoi(nobody.Gid)
+
+	tests := []struct {
+		name  string
+		mode  os.FileMode
+		owner bool
+		group bool
+		want  bool
+	}{
+		{"관리자만 쓸 수 있는 폴더", 0755, false, false, false},
+		{"모두 쓸 수 있는 폴더", 0777, false, false, true},
+		{"실행 권한이 없는 폴더", 0776, false, false, false},
+		{"사용자 소유 폴더", 0700, true, false, true},
+		{"사용자 소유지만 쓸 수 없는 폴더", 0500, true, false, false},
+		{"그룹이 쓸 수 있는 폴더", 0770, false, true, true},
+		{"그룹이 읽기만 할 수 있는 폴더", 0750, false, true, false},
+	}
+
+	for _, test := range tests {
+		dir := filepath.Join(t.TempDir(), "nicotrans")
+		if e := os.Mkdir(dir, 0700); e != nil {
+			t.Fatal(e)
+		}
+
+		owner, group := 0, 0
+		if test.owner {
+			owner = uid
+		}
+
+		if test.group {
+			group = gid
+		}
+
+		if e := os.Chown(dir, owner, group); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := os.Chmod(dir, test.mode); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := CheckWritable(dir, "nobody", ""); (e == nil) != test.want {
+			t.Errorf("%s: %v, 기대값 %v", test.name, e, test.want)
+		}
+	}
+
+	// 폴더가 아니라면 파일을 만들 수 없음
+	path := filepath.Join(t.TempDir(), "nicotrans.log")
+	if e := ioutil.WriteFile(path, nil, 0666); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := CheckWritable(path, "nobody", ""); e == nil {
+		t.Error("파일을 쓸 수 있는 폴더로 판단했습니다")
+	}
+
+	// 관리자 소유 파일을 넘겨줄 수 있어야 함
+	if e := ChownTo(path, "nobody", ""); e != nil {
+		t.Fatal(e)
+	}
+
+	info, e := os.Stat(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if stat := info.Sys().(*syscall.Stat_t); int(stat.Uid) != uid || int(stat.Gid) != gid {
+		t.Errorf("소유자 %d:%d, 기대값 %d:%d", stat.Uid, stat.Gid, uid, gid)
+	}
+
+	if e := ChownTo(path, "root", ""); e == nil {
+		t.Error("관리자 계정으로 소유자를 바꿨습니다")
+	}
+}
@@ -0,0 +1,15 @@
+package system
+
+import (
+	"fmt"
+)
+
+// ChownTo 윈도우는 다른 사용자로 권한을 내리는 기능을 지원하지 않습니다
+func ChownTo(path string, username string, group string) error {
+	return fmt.Errorf("리눅스 환경에서만 사용할 수 있는 메소드입니다")
+}
+
+// CheckWritable 윈도우는 다른 사용자로 권한을 내리는 기능을 지원하지 않습니다
+func CheckWritable(dir string, username string, group string) error {
+	return fmt.Errorf("리눅스 환경에서만 사용할 수 있는 메소드입니다")
+}
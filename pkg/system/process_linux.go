@@ -0,0 +1,64 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ProcessExists 프로세스가 실행 중인지?
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// 신호 0 은 보내지 않고 프로세스 존재 여부만 확인함
+	if e := syscall.Kill(pid, 0); e != nil && e != syscall.EPERM {
+		return false
+	}
+
+	// 종료됐지만 아직 회수되지 않은 좀비 프로세스는 실행 중이 아님
+	data, e := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if e != nil {
+		return true
+	}
+
+	stat := string(data)
+	if i := strings.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z'
+	}
+
+	return true
+}
+
+// ProcessStart 프로세스를 시작한 시점을 나타내는 값을 반환합니다
+// 프로세스 아이디는 다시 사용되므로 아이디와 함께 비교해 같은 프로세스인지 확인할 때 사용합니다
+// 부팅 아이디와 부팅 후 시작 시각 (클럭 틱) 을 합친 값이라 재부팅해도 겹치지 않습니다
+func ProcessStart(pid int) (string, error) {
+	data, e := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if e != nil {
+		return "", e
+	}
+
+	// 실행 파일 이름에 공백이 있을 수 있으므로 마지막 괄호 뒤부터 나누기
+	stat := string(data)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return "", fmt.Errorf("%d 프로세스 정보를 읽을 수 없습니다", pid)
+	}
+
+	// 괄호 뒤의 첫 필드는 상태 (3번째), 시작 시각은 22번째 필드
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return "", fmt.Errorf("%d 프로세스 정보를 읽을 수 없습니다", pid)
+	}
+
+	boot, e := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if e != nil {
+		return "", e
+	}
+
+	return strings.ReplaceAll(strings.TrimSpace(string(boot)), "-", "") + "." + fields[19], nil
+}
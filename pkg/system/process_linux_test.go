@@ -0,0 +1,51 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestProcessStart(t *testing.T) {
+	self, e := ProcessStart(os.Getpid())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// 같은 프로세스는 항상 같은 값을 반환해야 함
+	if again, _ := ProcessStart(os.Getpid()); again != self {
+		t.Errorf("%s, 기대값 %s", again, self)
+	}
+
+	cmd := exec.Command("sleep", "10")
+	if e := cmd.Start(); e != nil {
+		t.Skip(e)
+	}
+
+	child, e := ProcessStart(cmd.Process.Pid)
+	if e != nil {
+		t.Error(e)
+	}
+
+	if !ProcessExists(cmd.Process.Pid) {
+		t.Error("실행 중인 자식 프로세스를 찾지 못했습니다")
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	// 시작 시각은 클럭 틱 단위라 바로 만든 자식 프로세스와 같을 수 있지만 부팅 아이디는 같아야 함
+	if boot := self[:strings.IndexByte(self, '.')+1]; !strings.HasPrefix(child, boot) {
+		t.Errorf("%s, 기대값 %s 과 같은 부팅 아이디", child, self)
+	}
+
+	// 종료한 프로세스는 실행 중이 아니어야 함
+	if ProcessExists(cmd.Process.Pid) {
+		t.Error("종료한 프로세스가 실행 중입니다")
+	}
+
+	if _, e := ProcessStart(cmd.Process.Pid); e == nil {
+		t.Error("종료한 프로세스의 시작 시점을 반환했습니다")
+	}
+}
@@ -0,0 +1,55 @@
+package system
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// PROCESS_QUERY_LIMITED_INFORMATION
+	processQueryLimitedInformation = 0x1000
+	// GetExitCodeProcess 가 실행 중인 프로세스에 반환하는 값 (STILL_ACTIVE)
+	stillActive = 259
+)
+
+// ProcessExists 프로세스가 실행 중인지?
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	handle, e := windows.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if e != nil {
+		// 권한이 없어서 열 수 없다면 실행 중인 것
+		return e == windows.ERROR_ACCESS_DENIED
+	}
+
+	defer windows.CloseHandle(handle)
+
+	var code uint32
+	if e := windows.GetExitCodeProcess(handle, &code); e != nil {
+		return false
+	}
+
+	return code == stillActive
+}
+
+// ProcessStart 프로세스를 시작한 시점을 나타내는 값을 반환합니다
+// 프로세스 아이디는 다시 사용되므로 아이디와 함께 비교해 같은 프로세스인지 확인할 때 사용합니다
+// 프로세스를 만든 시각 (FILETIME) 이라 재부팅해도 겹치지 않습니다
+func ProcessStart(pid int) (string, error) {
+	handle, e := windows.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if e != nil {
+		return "", e
+	}
+
+	defer windows.CloseHandle(handle)
+
+	var creation, exit, kernel, user windows.Filetime
+	if e := windows.GetProcessTimes(handle, &creation, &exit, &kernel, &user); e != nil {
+		return "", e
+	}
+
+	return strconv.FormatInt(creation.Nanoseconds(), 10), nil
+}
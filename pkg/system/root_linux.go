@@ -1,13 +1,117 @@
 package system
 
-import "fmt"
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// capNetBindService 1024 미만 포트를 열 수 있는 권한 번호
+const capNetBindService = 10
 
 // HasRoot 관리자 권한이 있는지?
 func HasRoot() (bool, error) {
-	return false, fmt.Errorf("윈도우 환경에서만 사용할 수 있는 메소드입니다")
+	return os.Geteuid() == 0, nil
+}
+
+// CanBind 포트를 열 수 있는 권한이 있는지?
+// 관리자 권한이나 CAP_NET_BIND_SERVICE 가 있거나, 커널 설정상 일반 사용자도 열 수 있는 포트라면 true 를 반환합니다
+func CanBind(port int) (bool, error) {
+	if os.Geteuid() == 0 {
+		return true, nil
+	}
+
+	if port >= unprivilegedPortStart() {
+		return true, nil
+	}
+
+	return hasCapability(capNetBindService)
+}
+
+// unprivilegedPortStart 일반 사용자가 열 수 있는 가장 낮은 포트
+func unprivilegedPortStart() int {
+	data, e := ioutil.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if e != nil {
+		return 1024
+	}
+
+	port, e := strconv.Atoi(strings.TrimSpace(string(data)))
+	if e != nil {
+		return 1024
+	}
+
+	return port
+}
+
+// hasCapability 현재 프로세스의 유효 권한 (CapEff) 에 권한이 있는지?
+func hasCapability(capability uint) (bool, error) {
+	file, e := os.Open("/proc/self/status")
+	if e != nil {
+		return false, e
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+
+		caps, e := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if e != nil {
+			return false, e
+		}
+
+		return caps&(1<<capability) != 0, nil
+	}
+
+	if e := scanner.Err(); e != nil {
+		return false, e
+	}
+
+	return false, fmt.Errorf("프로세스 권한 정보를 찾을 수 없습니다")
 }
 
 // RunMeElevated 현재 프로그램을 관리자 권한으로 다시 실행합니다
+// 터미널에서 실행했다면 sudo, 그렇지 않다면 pkexec 을 사용하며 성공하면 반환하지 않습니다
 func RunMeElevated() error {
-	return fmt.Errorf("윈도우 환경에서만 사용할 수 있는 메소드입니다")
+	exe, e := os.Executable()
+	if e != nil {
+		return e
+	}
+
+	cwd, e := os.Getwd()
+	if e != nil {
+		return e
+	}
+
+	var argv []string
+
+	if sudo, e := exec.LookPath("sudo"); e == nil && isTerminal(os.Stdin) {
+		argv = append([]string{sudo, "--", exe}, os.Args[1:]...)
+	} else if pkexec, e := exec.LookPath("pkexec"); e == nil {
+		// pkexec 은 작업 폴더를 바꾸기 때문에 셸로 원래 폴더로 돌아가서 실행하기
+		argv = append([]string{pkexec, "/bin/sh", "-c", `cd "$0" && exec "$@"`, cwd, exe}, os.Args[1:]...)
+	} else {
+		return fmt.Errorf("관리자 권한을 얻을 수 있는 sudo 나 pkexec 을 찾을 수 없습니다")
+	}
+
+	return syscall.Exec(argv[0], argv, os.Environ())
+}
+
+// isTerminal 파일이 터미널인지?
+func isTerminal(file *os.File) bool {
+	info, e := file.Stat()
+	if e != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
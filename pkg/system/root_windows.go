@@ -55,3 +55,8 @@ func RunMeElevated() error {
 
 	return nil
 }
+
+// CanBind 포트를 열 수 있는 권한이 있는지? 윈도우는 포트 번호에 따른 제한이 없습니다
+func CanBind(port int) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,95 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// StartAs 현재 프로그램을 같은 인자로 다른 사용자 권한으로 실행합니다
+// 소켓은 systemd 소켓 활성화와 같은 방식 (LISTEN_FDS, LISTEN_FDNAMES) 으로 전달되기 때문에
+// 자식 프로세스는 InheritedFiles 로 받을 수 있습니다
+func StartAs(username string, group string, files []InheritedFile) (*os.Process, error) {
+	credential, e := lookupCredential(username, group)
+	if e != nil {
+		return nil, e
+	}
+
+	exe, e := os.Executable()
+	if e != nil {
+		return nil, e
+	}
+
+	names := make([]string, len(files))
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+
+	for i, file := range files {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, file.File)
+		names[i] = file.Name
+	}
+
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "LISTEN_") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+
+	cmd.Env = append(cmd.Env,
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+	)
+
+	if e := cmd.Start(); e != nil {
+		return nil, e
+	}
+
+	return cmd.Process, nil
+}
+
+// lookupCredential 사용자와 그룹 이름 또는 번호로 권한을 찾습니다, 그룹이 비어있다면 사용자의 기본 그룹을 사용합니다
+func lookupCredential(username string, group string) (*syscall.Credential, error) {
+	u, e := user.Lookup(username)
+	if e != nil {
+		if u, e = user.LookupId(username); e != nil {
+			return nil, fmt.Errorf("%s 사용자를 찾을 수 없습니다", username)
+		}
+	}
+
+	gid := u.Gid
+	if group != "" {
+		g, e := user.LookupGroup(group)
+		if e != nil {
+			if g, e = user.LookupGroupId(group); e != nil {
+				return nil, fmt.Errorf("%s 그룹을 찾을 수 없습니다", group)
+			}
+		}
+
+		gid = g.Gid
+	}
+
+	uid, e := strconv.ParseUint(u.Uid, 10, 32)
+	if e != nil {
+		return nil, e
+	}
+
+	g, e := strconv.ParseUint(gid, 10, 32)
+	if e != nil {
+		return nil, e
+	}
+
+	if uid == 0 {
+		return nil, fmt.Errorf("관리자 계정으로는 권한을 내릴 수 없습니다")
+	}
+
+	// 보조 그룹을 비워서 관리자의 그룹 권한을 물려받지 않게 하기
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(g), Groups: []uint32{}}, nil
+}
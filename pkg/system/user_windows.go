@@ -0,0 +1,11 @@
+package system
+
+import (
+	"fmt"
+	"os"
+)
+
+// StartAs 윈도우는 다른 사용자로 권한을 내리는 기능을 지원하지 않습니다
+func StartAs(username string, group string, files []InheritedFile) (*os.Process, error) {
+	return nil, fmt.Errorf("리눅스 환경에서만 사용할 수 있는 메소드입니다")
+}
@@ -0,0 +1,277 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 한 번에 보낼 최대 스팬 수
+const otlpBatchSize = 256
+
+// 보내지 못하고 기다릴 수 있는 최대 스팬 수, 넘으면 버립니다
+const otlpQueueSize = 4096
+
+// OTLPExporter 스팬을 모아 OTLP/HTTP JSON 형식으로 수집기에 보냅니다
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	interval    time.Duration
+	client      *http.Client
+	onError     func(error)
+
+	spans chan *Span
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	dropped uint64
+}
+
+// NewOTLPExporter endpoint 의 수집기로 interval 마다 스팬을 보내는 익스포터를 만듭니다
+// endpoint 가 /v1/traces 로 끝나지 않는다면 붙여서 사용하고, 보내지 못하면 onError 를 부릅니다
+func NewOTLPExporter(endpoint, serviceName string, interval time.Duration, onError func(error)) *OTLPExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+
+	x := &OTLPExporter{
+		url:         url,
+		serviceName: serviceName,
+		interval:    interval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		onError:     onError,
+		spans:       make(chan *Span, otlpQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go x.run()
+
+	return x
+}
+
+// ExportSpan 스팬을 보낼 목록에 넣습니다
+func (x *OTLPExporter) ExportSpan(span *Span) {
+	select {
+	case <-x.stop:
+		atomic.AddUint64(&x.dropped, 1)
+		return
+	default:
+	}
+
+	select {
+	case x.spans <- span:
+	default:
+		atomic.AddUint64(&x.dropped, 1)
+	}
+}
+
+// Dropped 목록이 가득 차서 버린 스팬 수
+func (x *OTLPExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&x.dropped)
+}
+
+// Shutdown 남은 스팬을 보내고 익스포터를 멈춥니다
+func (x *OTLPExporter) Shutdown(ctx context.Context) error {
+	x.once.Do(func() {
+		close(x.stop)
+	})
+
+	select {
+	case <-x.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (x *OTLPExporter) run() {
+	defer close(x.done)
+
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+
+	var batch []*Span
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if e := x.send(batch); e != nil && x.onError != nil {
+			x.onError(e)
+		}
+
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-x.spans:
+			batch = append(batch, span)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-x.stop:
+			for {
+				select {
+				case span := <-x.spans:
+					batch = append(batch, span)
+					if len(batch) >= otlpBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (x *OTLPExporter) send(spans []*Span) error {
+	data, e := json.Marshal(x.request(spans))
+	if e != nil {
+		return e
+	}
+
+	res, e := x.client.Post(x.url, "application/json", bytes.NewReader(data))
+	if e != nil {
+		return fmt.Errorf("스팬 %d개를 보내지 못했습니다: %s", len(spans), e)
+	}
+
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("수집기가 스팬 %d개를 받지 않았습니다: %s", len(spans), res.Status)
+	}
+
+	return nil
+}
+
+// OTLP JSON 형식, 아이디는 16진수 문자열이고 64비트 정수는 문자열로 씁니다
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// 상태 코드 2 는 오류
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (x *OTLPExporter) request(spans []*Span) otlpRequest {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: x.serviceName}}
+
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		}
+
+		if span.ParentID.IsValid() {
+			s.ParentSpanID = span.ParentID.String()
+		}
+
+		for _, attribute := range span.Attributes {
+			s.Attributes = append(s.Attributes, newOTLPAttribute(attribute.Key, attribute.Value))
+		}
+
+		if span.Error != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+
+		scope.Spans = append(scope.Spans, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			newOTLPAttribute("service.name", x.serviceName),
+		}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+func newOTLPAttribute(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+
+	switch value := value.(type) {
+	case bool:
+		v.BoolValue = &value
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &value
+	case string:
+		v.StringValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+
+	return otlpAttribute{Key: key, Value: v}
+}
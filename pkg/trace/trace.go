@@ -0,0 +1,183 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID 요청 하나를 처리하며 만든 스팬을 묶는 아이디
+type TraceID [16]byte
+
+// SpanID 스팬 아이디
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid 0 이 아닌 아이디인지?
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid 0 이 아닌 아이디인지?
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// Kind 스팬 종류, OTLP 의 SpanKind 값과 같습니다
+type Kind int
+
+// 스팬 종류
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attribute 스팬 속성
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span 작업 하나에 걸린 시간
+// 스팬을 만든 고루틴에서만 값을 바꿔야 하고 End 를 부른 뒤에는 바꾸지 않아야 합니다
+type Span struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Kind     Kind
+
+	Start time.Time
+	End   time.Time
+
+	Attributes []Attribute
+	Error      string
+
+	// 다른 서비스에서 넘겨받은 부모 스팬이라 내보내지 않는 스팬인지?
+	remote bool
+}
+
+type contextKey struct{}
+
+// Start 새 스팬을 시작합니다, ctx 에 스팬이 있다면 자식 스팬으로 만듭니다
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		Name:  name,
+		Kind:  KindInternal,
+		Start: time.Now(),
+	}
+
+	if parent := FromContext(ctx); parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		rand.Read(span.TraceID[:])
+	}
+
+	rand.Read(span.SpanID[:])
+
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// FromContext ctx 에 있는 스팬을 반환합니다
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// Extract W3C traceparent 헤더가 올바르다면 헤더의 스팬을 부모로 사용하는 ctx 를 반환합니다
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+
+	parent := &Span{remote: true}
+
+	if _, e := hex.Decode(parent.TraceID[:], []byte(parts[1])); e != nil || !parent.TraceID.IsValid() {
+		return ctx
+	}
+
+	if _, e := hex.Decode(parent.SpanID[:], []byte(parts[2])); e != nil || !parent.SpanID.IsValid() {
+		return ctx
+	}
+
+	return context.WithValue(ctx, contextKey{}, parent)
+}
+
+// SetAttribute 속성을 추가합니다, 같은 이름의 속성이 있다면 값을 바꿉니다
+func (s *Span) SetAttribute(key string, value interface{}) {
+	for i := range s.Attributes {
+		if s.Attributes[i].Key == key {
+			s.Attributes[i].Value = value
+			return
+		}
+	}
+
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: value})
+}
+
+// SetError 작업이 실패했다고 기록합니다
+func (s *Span) SetError(e error) {
+	if e != nil {
+		s.Error = e.Error()
+	}
+}
+
+// Finish 스팬을 끝내고 내보냅니다
+func (s *Span) Finish() {
+	if !s.End.IsZero() {
+		return
+	}
+
+	s.End = time.Now()
+
+	if exporter := currentExporter(); exporter != nil && !s.remote {
+		exporter.ExportSpan(s)
+	}
+}
+
+// Duration 스팬에 걸린 시간, 끝나지 않았다면 지금까지 걸린 시간을 반환합니다
+func (s *Span) Duration() time.Duration {
+	if s.End.IsZero() {
+		return time.Since(s.Start)
+	}
+
+	return s.End.Sub(s.Start)
+}
+
+// Exporter 끝난 스팬을 받아 내보냅니다, 요청 처리를 막지 않도록 바로 반환해야 합니다
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+var exporter struct {
+	mu sync.RWMutex
+	e  Exporter
+}
+
+// SetExporter 끝난 스팬을 내보낼 곳을 지정합니다, nil 이라면 내보내지 않습니다
+func SetExporter(e Exporter) {
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+
+	exporter.e = e
+}
+
+func currentExporter() Exporter {
+	exporter.mu.RLock()
+	defer exporter.mu.RUnlock()
+
+	return exporter.e
+}
@@ -0,0 +1,138 @@
+package translator
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheKey 캐시에서 번역 결과를 찾는 키
+type CacheKey struct {
+	Platform string
+	Source   string
+	Target   string
+	Text     string
+}
+
+// CacheStats 캐시 사용 통계
+type CacheStats struct {
+	Size    int
+	Len     int
+	Hits    uint64
+	Misses  uint64
+	Evicted uint64
+}
+
+// Cache 번역한 코멘트를 기억하는 LRU 캐시
+// 같은 동영상의 코멘트는 여러 번 요청되기 때문에 이미 번역한 코멘트는 번역기로 보내지 않습니다
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	items map[CacheKey]*list.Element
+	order *list.List
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key        CacheKey
+	translated string
+}
+
+// NewCache 코멘트를 size 개까지 기억하는 캐시를 만듭니다, 0 이하라면 아무것도 기억하지 않습니다
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:  size,
+		items: map[CacheKey]*list.Element{},
+		order: list.New(),
+	}
+}
+
+// Get 번역 결과를 찾습니다
+func (c *Cache) Get(key CacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return "", false
+	}
+
+	c.stats.Hits++
+	c.order.MoveToFront(item)
+
+	return item.Value.(*cacheEntry).translated, true
+}
+
+// Add 번역 결과를 기억합니다, 가득 찼다면 가장 오래 사용하지 않은 결과를 지웁니다
+func (c *Cache) Add(key CacheKey, translated string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	if item, ok := c.items[key]; ok {
+		item.Value.(*cacheEntry).translated = translated
+		c.order.MoveToFront(item)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, translated: translated})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.stats.Evicted++
+	}
+}
+
+// Stats 캐시 사용 통계를 반환합니다
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.size
+	stats.Len = c.order.Len()
+
+	return stats
+}
+
+// CacheEntry 캐시에 기억한 번역 결과
+type CacheEntry struct {
+	Key        CacheKey
+	Translated string
+}
+
+// Entries 최근에 사용한 순서로 번역 결과를 limit 개까지 반환합니다, 0 이하라면 모두 반환합니다
+func (c *Cache) Entries(limit int) []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit <= 0 || limit > c.order.Len() {
+		limit = c.order.Len()
+	}
+
+	entries := make([]CacheEntry, 0, limit)
+	for item := c.order.Front(); item != nil && len(entries) < limit; item = item.Next() {
+		entry := item.Value.(*cacheEntry)
+		entries = append(entries, CacheEntry{Key: entry.key, Translated: entry.translated})
+	}
+
+	return entries
+}
+
+// Flush 기억한 번역 결과를 모두 지우고 지운 개수를 반환합니다
+func (c *Cache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+
+	c.items = map[CacheKey]*list.Element{}
+	c.order.Init()
+
+	return n
+}
@@ -0,0 +1,141 @@
+package translator
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// key 파파고 일본어 → 한국어 번역 캐시 키를 만듭니다
+func key(text string) CacheKey {
+	return CacheKey{Platform: "papago", Source: "ja", Target: "ko", Text: text}
+}
+
+// texts 최근에 사용한 순서로 원문 목록을 반환합니다
+func texts(c *Cache) string {
+	var s string
+	for _, entry := range c.Entries(0) {
+		s += entry.Key.Text
+	}
+
+	return s
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache(3)
+
+	for _, text := range []string{"a", "b", "c"} {
+		c.Add(key(text), text+"!")
+	}
+
+	// 사용한 항목은 가장 최근으로 옮겨야 함
+	if translated, ok := c.Get(key("a")); !ok || translated != "a!" {
+		t.Fatalf("%s %v", translated, ok)
+	}
+
+	c.Add(key("d"), "d!")
+
+	if got := texts(c); got != "dac" {
+		t.Errorf("%s, 기대값 dac", got)
+	}
+
+	if _, ok := c.Get(key("b")); ok {
+		t.Error("가장 오래 사용하지 않은 항목을 지우지 않았습니다")
+	}
+
+	// 이미 있는 항목은 지우지 않고 바꿔야 함
+	c.Add(key("c"), "c?")
+
+	if translated, _ := c.Get(key("c")); translated != "c?" {
+		t.Errorf("%s, 기대값 c?", translated)
+	}
+
+	stats := c.Stats()
+	if stats.Size != 3 || stats.Len != 3 || stats.Evicted != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("%+v", stats)
+	}
+
+	if got := c.Entries(2); len(got) != 2 || got[0].Key.Text != "c" || got[1].Key.Text != "d" {
+		t.Errorf("%+v", got)
+	}
+
+	if n := c.Flush(); n != 3 || c.Stats().Len != 0 || len(c.Entries(0)) != 0 {
+		t.Errorf("지운 개수 %d, %+v", n, c.Stats())
+	}
+
+	// 비운 뒤에도 사용할 수 있어야 함
+	c.Add(key("e"), "e!")
+
+	if got := texts(c); got != "e" {
+		t.Errorf("%s, 기대값 e", got)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	c := NewCache(10)
+	c.Add(key("こんにちは"), "안녕하세요")
+
+	tests := []struct {
+		key  CacheKey
+		want bool
+	}{
+		{key("こんにちは"), true},
+		{CacheKey{Platform: "papago", Source: "ja", Target: "en", Text: "こんにちは"}, false},
+		{CacheKey{Platform: "papago", Source: "zh-CN", Target: "ko", Text: "こんにちは"}, false},
+		{CacheKey{Platform: "google", Source: "ja", Target: "ko", Text: "こんにちは"}, false},
+		{key("こんにちは "), false},
+		{key("こんばんは"), false},
+	}
+
+	for _, test := range tests {
+		if _, ok := c.Get(test.key); ok != test.want {
+			t.Errorf("%+v: %v, 기대값 %v", test.key, ok, test.want)
+		}
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		c := NewCache(size)
+		c.Add(key("a"), "a!")
+
+		if _, ok := c.Get(key("a")); ok || c.Stats().Len != 0 {
+			t.Errorf("크기 %d: %+v", size, c.Stats())
+		}
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(50)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 500; j++ {
+				k := key(fmt.Sprint(j % 100))
+
+				if translated, ok := c.Get(k); ok && translated != k.Text+"!" {
+					t.Errorf("%s: %s", k.Text, translated)
+				}
+
+				c.Add(k, k.Text+"!")
+
+				if j%100 == 0 {
+					c.Entries(10)
+					c.Stats()
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Len > 50 || stats.Hits+stats.Misses != 20*500 {
+		t.Errorf("%+v", stats)
+	}
+}
@@ -1,6 +1,7 @@
 package translator
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -21,7 +22,7 @@ type papagoResponsePayload struct {
 
 var papagoMaxLength = 5000
 
-func translatePapago(text string, source string, target string) <-chan TranslateSequence {
+func translatePapago(ctx context.Context, text string, source string, target string) <-chan TranslateSequence {
 	resolve := make(chan TranslateSequence)
 
 	go func() {
@@ -48,14 +49,21 @@ func translatePapago(text string, source string, target string) <-chan Translate
 		payload := url.Values{}
 		payload.Add("data", string(data))
 
-		res, e := http.Post(
+		req, e := http.NewRequest(
+			http.MethodPost,
 			"https://papago.naver.com/apis/n2mt/translate",
-			"x-www-form-urlencoded",
 			strings.NewReader(payload.Encode()))
 		if e != nil {
 			return
 		}
 
+		req.Header.Set("Content-Type", "x-www-form-urlencoded")
+
+		res, e := http.DefaultClient.Do(req.WithContext(ctx))
+		if e != nil {
+			return
+		}
+
 		defer res.Body.Close()
 
 		body, e := ioutil.ReadAll(res.Body)
@@ -64,12 +72,12 @@ func translatePapago(text string, source string, target string) <-chan Translate
 		}
 
 		if res.StatusCode != 200 {
-			fmt.Println(payload.Encode())
-			fmt.Println(res.Status)
+			e = fmt.Errorf("파파고가 요청을 처리하지 못했습니다: %s", res.Status)
+			return
 		}
 
 		var response papagoResponsePayload
-		if e := json.Unmarshal(body, &response); e != nil {
+		if e = json.Unmarshal(body, &response); e != nil {
 			return
 		}
 
@@ -2,8 +2,13 @@ package translator
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"unicode/utf8"
+
+	"github.com/hype5/nicotrans-go/pkg/trace"
 )
 
 // TranslateSequence 번역 시퀀스
@@ -18,20 +23,77 @@ type TranslateSequence struct {
 type TranslateResult struct {
 	Sequences []TranslateSequence
 	Error     error
+
+	// 번역기로 보낸 청크 수와 글자 수
+	Chunks     int
+	Characters int
+}
+
+// IsPlatform 사용할 수 있는 번역 플랫폼인지?
+func IsPlatform(platform string) bool {
+	switch platform {
+	case "papago":
+		return true
+	default:
+		return false
+	}
+}
+
+// 번역 플랫폼별로 지원하는 언어 코드
+var languages = map[string][]string{
+	"papago": {"ko", "en", "ja", "zh-CN", "zh-TW", "vi", "id", "th", "de", "ru", "es", "it", "fr"},
+}
+
+// Platforms 사용할 수 있는 번역 플랫폼 목록을 반환합니다
+func Platforms() []string {
+	platforms := make([]string, 0, len(languages))
+	for platform := range languages {
+		platforms = append(platforms, platform)
+	}
+
+	sort.Strings(platforms)
+
+	return platforms
+}
+
+// Languages 번역 플랫폼이 지원하는 언어 코드를 반환합니다
+func Languages(platform string) []string {
+	return languages[platform]
 }
 
-// Translate 번역합니다
-func Translate(queries []string, platform, source, target string) <-chan TranslateResult {
+// IsLanguage 번역 플랫폼이 지원하는 언어 코드인지?
+func IsLanguage(platform, language string) bool {
+	for _, l := range languages[platform] {
+		if l == language {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Translate 번역합니다, ctx 가 취소되면 번역기 요청을 멈춥니다
+func Translate(ctx context.Context, queries []string, platform, source, target string) <-chan TranslateResult {
 	resolve := make(chan TranslateResult)
 
 	go func() {
 		var r TranslateResult
 
+		ctx, span := trace.Start(ctx, "translator.Translate")
+		span.SetAttribute("translator.platform", platform)
+		span.SetAttribute("translator.source", source)
+		span.SetAttribute("translator.target", target)
+
 		defer func() {
+			span.SetAttribute("translator.chunks", r.Chunks)
+			span.SetAttribute("translator.characters", r.Characters)
+			span.SetError(r.Error)
+			span.Finish()
+
 			resolve <- r
 		}()
 
-		var translate func(string, string, string) <-chan TranslateSequence
+		var translate func(context.Context, string, string, string) <-chan TranslateSequence
 		var translateMaxLength int
 
 		switch platform {
@@ -63,6 +125,11 @@ func Translate(queries []string, platform, source, target string) <-chan Transla
 		}
 
 		r.Sequences = make([]TranslateSequence, len(queries))
+		r.Chunks = len(chunks)
+
+		for _, chunk := range chunks {
+			r.Characters += utf8.RuneCount(chunk.Bytes())
+		}
 
 		// 청크 번역
 		var wg sync.WaitGroup
@@ -71,8 +138,18 @@ func Translate(queries []string, platform, source, target string) <-chan Transla
 		for index, chunk := range chunks {
 			go func(index int, text string) {
 				defer wg.Done()
-				r.Sequences[index] = <-translate(text, source, target)
+
+				ctx, span := trace.Start(ctx, "translator.chunk")
+				span.Kind = trace.KindClient
+				span.SetAttribute("translator.platform", platform)
+				span.SetAttribute("translator.chunk", index)
+				span.SetAttribute("translator.characters", utf8.RuneCountInString(text))
+
+				r.Sequences[index] = <-translate(ctx, text, source, target)
 				r.Sequences[index].Index = index
+
+				span.SetError(r.Sequences[index].Error)
+				span.Finish()
 			}(index, chunk.String())
 		}
 